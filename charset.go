@@ -0,0 +1,118 @@
+package request
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// charsetPrescan is how much of the body is searched for a declared charset,
+// more than the 1024 bytes of the HTML spec as plenty of pages put the meta
+// tag after scripts and styles.
+const charsetPrescan = 4096
+
+var (
+	xmlDeclReg = regexp.MustCompile(`^\s*<\?xml[^>]*?\bencoding\s*=\s*["']([\w.:-]+)["']`)
+	// matches <meta charset="x"> as well as the content of http-equiv tags
+	metaCharsetReg = regexp.MustCompile(`(?is)<meta\b[^>]*?\bcharset\s*=\s*["']?\s*([\w.:-]+)`)
+)
+
+var boms = []struct {
+	bom  []byte
+	name string
+	enc  encoding.Encoding
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8", unicode.UTF8},
+	{[]byte{0xFE, 0xFF}, "utf-16be", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	{[]byte{0xFF, 0xFE}, "utf-16le", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+}
+
+// Charset returns the charset the body is decoded with by Text, as declared
+// by a BOM, the Content-Type header, an XML declaration or a meta tag, in
+// that order. Undeclared bodies are utf-8 if valid and gb18030 otherwise.
+func (r *Response) Charset() string {
+	if r.charset == "" {
+		r.Text()
+	}
+	return r.charset
+}
+
+// decodeBody decodes s to UTF-8 and returns the name of the charset it was
+// found to be in, s is returned as is if it doesn't decode.
+func decodeBody(s []byte, contentType string) ([]byte, string) {
+	s, name, enc := detectCharset(s, contentType)
+	if enc == nil || enc == unicode.UTF8 {
+		return s, name
+	}
+	d, err := io.ReadAll(transform.NewReader(bytes.NewReader(s), enc.NewDecoder()))
+	if err != nil {
+		return s, name
+	}
+	return d, name
+}
+
+// detectCharset finds the encoding of s, with the BOM stripped off if it
+// has one.
+func detectCharset(s []byte, contentType string) ([]byte, string, encoding.Encoding) {
+	for _, b := range boms {
+		if bytes.HasPrefix(s, b.bom) {
+			return s[len(b.bom):], b.name, b.enc
+		}
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if name, enc := lookupCharset(params["charset"]); enc != nil {
+			return s, name, enc
+		}
+	}
+
+	head := s
+	if len(head) > charsetPrescan {
+		head = head[:charsetPrescan]
+	}
+	if m := xmlDeclReg.FindSubmatch(head); m != nil {
+		if name, enc := lookupCharset(string(m[1])); enc != nil {
+			return s, name, enc
+		}
+	}
+	if m := metaCharsetReg.FindSubmatch(head); m != nil {
+		name, enc := lookupCharset(string(m[1]))
+		// an HTML document declaring utf-16 in ASCII can't be utf-16
+		if strings.HasPrefix(name, "utf-16") {
+			return s, "utf-8", unicode.UTF8
+		}
+		if enc != nil {
+			return s, name, enc
+		}
+	}
+
+	if utf8.Valid(s) {
+		return s, "utf-8", unicode.UTF8
+	}
+	return s, "gb18030", simplifiedchinese.GB18030
+}
+
+// lookupCharset resolves a charset label to its canonical name and encoding,
+// enc is nil for unknown labels.
+func lookupCharset(label string) (name string, enc encoding.Encoding) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return "", nil
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return "", nil
+	}
+	if name, err = htmlindex.Name(enc); err != nil {
+		name = strings.ToLower(label)
+	}
+	return name, enc
+}
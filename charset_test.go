@@ -0,0 +1,39 @@
+package request
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCharset(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		charset     string
+		text        string // contained in Text
+	}{
+		{"utf-8 bom", "text/html; charset=iso-8859-1", "\xef\xbb\xbfcaf\xc3\xa9", "utf-8", "café"},
+		{"utf-16le bom", "text/html; charset=utf-8", "\xff\xfeh\x00i\x00", "utf-16le", "hi"},
+		{"header", "text/html; charset=ISO-8859-1", `<meta charset="utf-8">caf` + "\xe9", "windows-1252", "café"},
+		{"unknown header charset", "text/html; charset=nope", `<meta charset="shift_jis">` + "\x93\xfa\x96\x7b", "shift_jis", "日本"},
+		{"xml declaration", "text/xml", `<?xml version="1.0" encoding="Shift_JIS"?><meta charset="utf-8">` + "\x93\xfa\x96\x7b", "shift_jis", "日本"},
+		{"meta charset", "text/html", `<html><head><meta charset="Shift_JIS"></head>` + "\x93\xfa\x96\x7b", "shift_jis", "日本"},
+		{"meta http-equiv", "text/html", `<meta http-equiv="Content-Type" content="text/html; charset=gbk">` + "\xc4\xe3\xba\xc3", "gbk", "你好"},
+		{"meta utf-16", "text/html", `<meta charset="utf-16">caf` + "\xc3\xa9", "utf-8", "café"},
+		{"meta past the prescan", "text/html", strings.Repeat(" ", charsetPrescan) + `<meta charset="shift_jis">ok`, "utf-8", "ok"},
+		{"valid utf-8", "text/html", "caf\xc3\xa9", "utf-8", "café"},
+		{"fallback", "text/html", "\xc4\xe3\xba\xc3", "gb18030", "你好"},
+	} {
+		resp := AcquireResponse()
+		resp.Header.SetContentType(tc.contentType)
+		resp.SetBodyString(tc.body)
+		if got := resp.Charset(); got != tc.charset {
+			t.Errorf("%s: got charset %s, want %s", tc.name, got, tc.charset)
+		}
+		if got := resp.Text(); !strings.Contains(got, tc.text) || strings.HasPrefix(got, "\ufeff") {
+			t.Errorf("%s: got text %q, want %q", tc.name, got, tc.text)
+		}
+		ReleaseResponse(resp)
+	}
+}
@@ -0,0 +1,307 @@
+package request
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"golang.org/x/net/html"
+)
+
+var (
+	formSelector    = cascadia.MustCompile("form")
+	linkSelector    = cascadia.MustCompile("a[href], area[href]")
+	scriptSelector  = cascadia.MustCompile("script")
+	metaSelector    = cascadia.MustCompile("meta")
+	controlSelector = cascadia.MustCompile("input, select, textarea, button")
+	optionSelector  = cascadia.MustCompile("option")
+	baseSelector    = cascadia.MustCompile("base[href]")
+)
+
+// Element is a node of the parsed body
+type Element struct {
+	*html.Node
+}
+
+// Text returns the text inside the element with surrounding space trimmed,
+// for an attribute selected by XPath it is the attribute's value.
+func (e Element) Text() string {
+	return strings.TrimSpace(htmlquery.InnerText(e.Node))
+}
+
+// Attribute returns the value of the attribute k
+func (e Element) Attribute(k string) (string, bool) {
+	return attr(e.Node, k)
+}
+
+// HTML returns the element rendered with its children
+func (e Element) HTML() string {
+	return htmlquery.OutputHTML(e.Node, true)
+}
+
+// Document returns the body parsed as HTML, it is parsed once from Text.
+func (r *Response) Document() (*html.Node, error) {
+	if r.doc != nil {
+		return r.doc, nil
+	}
+	doc, err := html.Parse(strings.NewReader(r.Text()))
+	if err != nil {
+		return nil, err
+	}
+	r.doc = doc
+	return doc, nil
+}
+
+// Find returns the elements matching the CSS selector in document order
+func (r *Response) Find(selector string) ([]Element, error) {
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.Document()
+	if err != nil {
+		return nil, err
+	}
+	return elements(sel.MatchAll(doc)), nil
+}
+
+// XPath returns the nodes selected by the XPath expression, attributes
+// selected with @ are elements whose Text is the attribute value.
+func (r *Response) XPath(expr string) ([]Element, error) {
+	doc, err := r.Document()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := htmlquery.QueryAll(doc, expr)
+	if err != nil {
+		return nil, err
+	}
+	return elements(nodes), nil
+}
+
+// Form is a form of the body with its controls
+type Form struct {
+	Action  string // as written, relative to the page
+	Method  string // upper case, GET if not set
+	Enctype string
+	ID      string
+	Name    string
+	Inputs  []Input
+}
+
+// Input is a control of a form. A select is an Input per selected option,
+// the value of a textarea is its text.
+type Input struct {
+	Name     string
+	Type     string // lower case, "select" and "textarea" for those elements
+	Value    string
+	Checked  bool // whether a checkbox or radio is checked
+	Disabled bool
+}
+
+// Values returns the name and value of the controls the form submits, in
+// document order. Buttons, files, unchecked boxes and disabled controls are
+// left out.
+func (f *Form) Values() DataList {
+	var values DataList
+	for _, in := range f.Inputs {
+		if in.Name == "" || in.Disabled {
+			continue
+		}
+		switch in.Type {
+		case "submit", "button", "reset", "image", "file":
+			continue
+		case "checkbox", "radio":
+			if !in.Checked {
+				continue
+			}
+		}
+		values = append(values, Pair{Key: in.Name, Value: in.Value})
+	}
+	return values
+}
+
+// Link is an a or area element with a href
+type Link struct {
+	URL  string // as written, relative to the page
+	Text string
+	Rel  string
+}
+
+// Script is a script element, Content is empty for external ones
+type Script struct {
+	Src     string
+	Type    string
+	Content string
+}
+
+// Meta is a meta element
+type Meta struct {
+	Name      string
+	Property  string // OpenGraph and the like
+	HTTPEquiv string
+	Charset   string
+	Content   string
+}
+
+// Forms returns the forms of the body
+func (r *Response) Forms() []Form {
+	var forms []Form
+	for _, n := range r.findAll(formSelector) {
+		forms = append(forms, parseForm(n))
+	}
+	return forms
+}
+
+// Links returns the links of the body
+func (r *Response) Links() []Link {
+	var links []Link
+	for _, n := range r.findAll(linkSelector) {
+		href, _ := attr(n, "href")
+		rel, _ := attr(n, "rel")
+		links = append(links, Link{URL: strings.TrimSpace(href), Text: Element{n}.Text(), Rel: rel})
+	}
+	return links
+}
+
+// Scripts returns the script elements of the body
+func (r *Response) Scripts() []Script {
+	var scripts []Script
+	for _, n := range r.findAll(scriptSelector) {
+		src, _ := attr(n, "src")
+		typ, _ := attr(n, "type")
+		scripts = append(scripts, Script{Src: strings.TrimSpace(src), Type: typ, Content: htmlquery.InnerText(n)})
+	}
+	return scripts
+}
+
+// Metas returns the meta elements of the body
+func (r *Response) Metas() []Meta {
+	var metas []Meta
+	for _, n := range r.findAll(metaSelector) {
+		var m Meta
+		m.Name, _ = attr(n, "name")
+		m.Property, _ = attr(n, "property")
+		m.HTTPEquiv, _ = attr(n, "http-equiv")
+		m.Charset, _ = attr(n, "charset")
+		m.Content, _ = attr(n, "content")
+		metas = append(metas, m)
+	}
+	return metas
+}
+
+// findAll matches sel against the document, nothing matches if the body
+// can't be parsed.
+func (r *Response) findAll(sel cascadia.Selector) []*html.Node {
+	doc, err := r.Document()
+	if err != nil {
+		return nil
+	}
+	return sel.MatchAll(doc)
+}
+
+// base returns the URL relative references of the document resolve
+// against, which is the URL of the response unless a base element says
+// otherwise.
+func (r *Response) base(doc *html.Node) (*url.URL, error) {
+	base, err := url.Parse(r.url)
+	if err != nil {
+		return nil, err
+	}
+	if b := baseSelector.MatchFirst(doc); b != nil {
+		href, _ := attr(b, "href")
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	return base, nil
+}
+
+// parseForm reads the attributes and controls of a form element
+func parseForm(n *html.Node) Form {
+	f := Form{Method: "GET"}
+	f.Action, _ = attr(n, "action")
+	if method, ok := attr(n, "method"); ok && strings.TrimSpace(method) != "" {
+		f.Method = strings.ToUpper(strings.TrimSpace(method))
+	}
+	f.Enctype, _ = attr(n, "enctype")
+	f.ID, _ = attr(n, "id")
+	f.Name, _ = attr(n, "name")
+
+	for _, c := range controlSelector.MatchAll(n) {
+		in := Input{}
+		in.Name, _ = attr(c, "name")
+		_, in.Disabled = attr(c, "disabled")
+		switch c.Data {
+		case "select":
+			in.Type = "select"
+			f.Inputs = append(f.Inputs, selected(c, in)...)
+			continue
+		case "textarea":
+			in.Type = "textarea"
+			in.Value = htmlquery.InnerText(c)
+		case "button":
+			in.Type = "submit"
+			if typ, ok := attr(c, "type"); ok {
+				in.Type = strings.ToLower(typ)
+			}
+			in.Value, _ = attr(c, "value")
+		default:
+			in.Type = "text"
+			if typ, ok := attr(c, "type"); ok && typ != "" {
+				in.Type = strings.ToLower(typ)
+			}
+			in.Value, _ = attr(c, "value")
+			_, in.Checked = attr(c, "checked")
+			if (in.Type == "checkbox" || in.Type == "radio") && in.Value == "" {
+				in.Value = "on"
+			}
+		}
+		f.Inputs = append(f.Inputs, in)
+	}
+	return f
+}
+
+// selected returns an Input like in for every selected option of a select,
+// or for its first option if a single select has none selected.
+func selected(n *html.Node, in Input) []Input {
+	var inputs []Input
+	options := optionSelector.MatchAll(n)
+	for _, o := range options {
+		if _, ok := attr(o, "selected"); ok {
+			inputs = append(inputs, option(o, in))
+		}
+	}
+	if _, multiple := attr(n, "multiple"); len(inputs) == 0 && !multiple && len(options) > 0 {
+		inputs = append(inputs, option(options[0], in))
+	}
+	return inputs
+}
+
+func option(o *html.Node, in Input) Input {
+	in.Checked = true
+	if v, ok := attr(o, "value"); ok {
+		in.Value = v
+	} else {
+		in.Value = strings.TrimSpace(htmlquery.InnerText(o))
+	}
+	return in
+}
+
+func attr(n *html.Node, k string) (string, bool) {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, k) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func elements(nodes []*html.Node) []Element {
+	es := make([]Element, 0, len(nodes))
+	for _, n := range nodes {
+		es = append(es, Element{n})
+	}
+	return es
+}
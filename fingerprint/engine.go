@@ -0,0 +1,313 @@
+package fingerprint
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/12end/request"
+	"github.com/valyala/fasthttp"
+)
+
+// Product is a product found in a response
+type Product struct {
+	Name       string
+	Version    string // empty if no matching pattern extracts one
+	Confidence int    // 1 to 100
+}
+
+// Engine matches compiled rules against responses. It is safe for
+// concurrent use.
+type Engine struct {
+	rules []*compiled
+	// Invalid lists the patterns left out as they aren't valid Go regular
+	// expressions, Wappalyzer ones sometimes use lookarounds.
+	Invalid []string
+}
+
+type compiled struct {
+	name    string
+	headers map[string][]pattern // by lower case name
+	cookies map[string][]pattern
+	title   []pattern
+	body    []pattern
+	meta    map[string][]pattern // by lower case name
+	scripts []pattern
+	favicon []int32
+	implies []implied
+}
+
+type pattern struct {
+	re         *regexp.Regexp // nil matches anything
+	version    string
+	confidence int
+}
+
+type implied struct {
+	name       string
+	confidence int
+}
+
+var (
+	backrefReg = regexp.MustCompile(`\\(\d)`)
+	// \1?found:missing picks a version depending on whether group 1 matched
+	ternaryReg = regexp.MustCompile(`\\(\d)\?([^:]*):(.*)`)
+)
+
+// New compiles rules into an Engine
+func New(rules []*Rule) *Engine {
+	e := &Engine{}
+	for _, r := range rules {
+		c := &compiled{
+			name:    r.Name,
+			headers: e.compileMap(r.Name, r.Headers, true),
+			cookies: e.compileMap(r.Name, r.Cookies, false),
+			title:   e.compileAll(r.Name, r.Title),
+			body:    e.compileAll(r.Name, r.Body),
+			meta:    e.compileMap(r.Name, r.Meta, true),
+			scripts: e.compileAll(r.Name, r.Scripts),
+			favicon: r.Favicon,
+		}
+		for _, s := range r.Implies {
+			name, _, confidence := splitTags(s)
+			c.implies = append(c.implies, implied{name: strings.TrimSpace(name), confidence: confidence})
+		}
+		e.rules = append(e.rules, c)
+	}
+	return e
+}
+
+func (e *Engine) compileMap(name string, m map[string]Patterns, fold bool) map[string][]pattern {
+	if len(m) == 0 {
+		return nil
+	}
+	compiled := make(map[string][]pattern, len(m))
+	for k, ps := range m {
+		if fold {
+			k = strings.ToLower(k)
+		}
+		if len(ps) == 0 {
+			// a key without patterns only has to be present
+			ps = Patterns{""}
+		}
+		compiled[k] = append(compiled[k], e.compileAll(name, ps)...)
+	}
+	return compiled
+}
+
+func (e *Engine) compileAll(name string, ps Patterns) []pattern {
+	var compiled []pattern
+	for _, s := range ps {
+		expr, version, confidence := splitTags(s)
+		p := pattern{version: version, confidence: confidence}
+		if expr != "" {
+			re, err := regexp.Compile("(?i)" + expr)
+			if err != nil {
+				e.Invalid = append(e.Invalid, name+": "+s)
+				continue
+			}
+			p.re = re
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled
+}
+
+// splitTags splits the Wappalyzer tags off a pattern
+func splitTags(s string) (expr, version string, confidence int) {
+	parts := strings.Split(s, `\;`)
+	confidence = 100
+	for _, tag := range parts[1:] {
+		k, v, _ := strings.Cut(tag, ":")
+		switch k {
+		case "version":
+			version = v
+		case "confidence":
+			if n, err := strconv.Atoi(v); err == nil {
+				confidence = n
+			}
+		}
+	}
+	return parts[0], version, confidence
+}
+
+// target is what the rules are matched against, extracted once per response
+type target struct {
+	headers map[string][]string
+	cookies map[string][]string
+	title   string
+	body    string
+	meta    map[string][]string
+	scripts []string
+	favicon []int32
+}
+
+func newTarget(resp *request.Response, favicons []int32) *target {
+	t := &target{
+		headers: map[string][]string{},
+		cookies: map[string][]string{},
+		meta:    map[string][]string{},
+		title:   resp.Title(),
+		body:    resp.Text(),
+		favicon: favicons,
+	}
+	resp.Header.VisitAll(func(k, v []byte) {
+		key := strings.ToLower(string(k))
+		t.headers[key] = append(t.headers[key], string(v))
+	})
+	c := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(c)
+	resp.Header.VisitAllCookie(func(_, v []byte) {
+		if c.ParseBytes(v) == nil {
+			t.cookies[string(c.Key())] = append(t.cookies[string(c.Key())], string(c.Value()))
+		}
+	})
+	for _, m := range resp.Metas() {
+		for _, k := range []string{m.Name, m.Property, m.HTTPEquiv} {
+			if k != "" {
+				k = strings.ToLower(k)
+				t.meta[k] = append(t.meta[k], m.Content)
+			}
+		}
+	}
+	for _, s := range resp.Scripts() {
+		if s.Src != "" {
+			t.scripts = append(t.scripts, s.Src)
+		}
+	}
+	return t
+}
+
+// Match returns the products found in resp sorted by name, including the
+// ones implied by them. favicons are the hashes of the favicons of the site,
+// see request.Response.FaviconHash.
+func (e *Engine) Match(resp *request.Response, favicons ...int32) []Product {
+	t := newTarget(resp, favicons)
+	found := map[string]*Product{}
+	var order []*compiled
+	for _, c := range e.rules {
+		if p := c.match(t); p != nil {
+			if prev, ok := found[c.name]; ok {
+				merge(prev, p)
+				continue
+			}
+			found[c.name] = p
+			order = append(order, c)
+		}
+	}
+
+	// implied products are as certain as the product implying them at most
+	byName := map[string][]*compiled{}
+	for _, c := range e.rules {
+		byName[c.name] = append(byName[c.name], c)
+	}
+	for i := 0; i < len(order); i++ {
+		c := order[i]
+		for _, imp := range c.implies {
+			if _, ok := found[imp.name]; ok || imp.name == "" {
+				continue
+			}
+			found[imp.name] = &Product{Name: imp.name, Confidence: min(found[c.name].Confidence, imp.confidence)}
+			order = append(order, byName[imp.name]...)
+		}
+	}
+
+	products := make([]Product, 0, len(found))
+	for _, p := range found {
+		products = append(products, *p)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+	return products
+}
+
+// match returns the product if any pattern of c matches t
+func (c *compiled) match(t *target) *Product {
+	var p *Product
+	add := func(ps []pattern, values ...string) {
+		for _, pat := range ps {
+			for _, v := range values {
+				version, ok := pat.match(v)
+				if !ok {
+					continue
+				}
+				if p == nil {
+					p = &Product{Name: c.name}
+				}
+				merge(p, &Product{Version: version, Confidence: pat.confidence})
+				break
+			}
+		}
+	}
+	for k, ps := range c.headers {
+		add(ps, t.headers[k]...)
+	}
+	for k, ps := range c.cookies {
+		add(ps, t.cookies[k]...)
+	}
+	for k, ps := range c.meta {
+		add(ps, t.meta[k]...)
+	}
+	add(c.title, t.title)
+	add(c.body, t.body)
+	add(c.scripts, t.scripts...)
+	for _, h := range c.favicon {
+		for _, f := range t.favicon {
+			if h == f {
+				if p == nil {
+					p = &Product{Name: c.name}
+				}
+				merge(p, &Product{Confidence: 100})
+			}
+		}
+	}
+	return p
+}
+
+// merge adds the confidence of q to p and keeps the most specific version
+func merge(p, q *Product) {
+	p.Confidence = min(p.Confidence+q.Confidence, 100)
+	if len(q.Version) > len(p.Version) || (len(q.Version) == len(p.Version) && q.Version > p.Version) {
+		p.Version = q.Version
+	}
+}
+
+// match reports whether p matches s and returns the version it extracts
+func (p pattern) match(s string) (string, bool) {
+	if p.re == nil {
+		return "", true
+	}
+	m := p.re.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	if p.version == "" {
+		return "", true
+	}
+	group := func(n string) string {
+		i, _ := strconv.Atoi(n)
+		if i < len(m) {
+			return m[i]
+		}
+		return ""
+	}
+	v := p.version
+	if t := ternaryReg.FindStringSubmatch(v); t != nil {
+		choice := t[3]
+		if group(t[1]) != "" {
+			choice = t[2]
+		}
+		v = strings.Replace(v, t[0], choice, 1)
+	}
+	v = backrefReg.ReplaceAllStringFunc(v, func(ref string) string {
+		return group(ref[1:])
+	})
+	return strings.TrimSpace(v), true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/12end/request"
+)
+
+const wappalyzerFixture = `{
+	"technologies": {
+		"Nginx": {"headers": {"Server": "nginx(?:/([\\d.]+))?\\;version:\\1"}},
+		"PHP": {"headers": {"X-Powered-By": "^php/?([\\d.]+)?\\;version:\\1"}, "cookies": {"PHPSESSID": ""}},
+		"WordPress": {
+			"meta": {"generator": ["^WordPress ?([\\d.]+)?\\;version:\\1"]},
+			"html": "/wp-(?:content|includes)/",
+			"implies": ["PHP", "MySQL\\;confidence:50"]
+		},
+		"MySQL": {},
+		"jQuery": {"scriptSrc": "jquery(?:-(\\d+\\.\\d+\\.\\d+))?[/.-]\\;version:\\1?\\1:unknown"},
+		"Varnish": {"headers": {"Via": "varnish\\;confidence:50", "X-Varnish": ""}},
+		"Lookaround": {"html": "(?<=a)b"}
+	}
+}`
+
+const yamlFixture = `
+- name: Admin panel
+  title: 'Admin (\w+)\;version:\1'
+  headers:
+    X-Admin:
+- name: Tomcat
+  body:
+    - 'Apache Tomcat/([\d.]+)\;version:\1'
+    - 'tomcat\;confidence:30'
+  implies: Java
+- name: Java
+- name: Favicon only
+  favicon: [116323821]
+`
+
+// response builds a response with the headers, given as name and value
+// pairs, and the body.
+func response(body string, headers ...string) *request.Response {
+	resp := request.AcquireResponse()
+	for i := 0; i+1 < len(headers); i += 2 {
+		resp.Header.Add(headers[i], headers[i+1])
+	}
+	resp.SetBodyString(body)
+	return resp
+}
+
+func TestMatch(t *testing.T) {
+	wappalyzer, err := LoadWappalyzer(strings.NewReader(wappalyzerFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlRules, err := LoadYAML(strings.NewReader(yamlFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(append(wappalyzer, yamlRules...))
+	if len(e.Invalid) != 1 || !strings.HasPrefix(e.Invalid[0], "Lookaround: ") {
+		t.Errorf("got invalid patterns %q", e.Invalid)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		resp     *request.Response
+		favicons []int32
+		want     []Product
+	}{
+		{
+			name: "wordpress",
+			resp: response(`<html><head><meta name="Generator" content="WordPress 6.4.2">
+				<script src="/js/jquery-3.7.1.min.js"></script></head></html>`,
+				"Server", "nginx/1.25.3", "Set-Cookie", "PHPSESSID=abc; path=/", "Via", "1.1 varnish"),
+			want: []Product{
+				{"MySQL", "", 50},
+				{"Nginx", "1.25.3", 100},
+				{"PHP", "", 100},
+				{"Varnish", "", 50},
+				{"WordPress", "6.4.2", 100},
+				{"jQuery", "3.7.1", 100},
+			},
+		},
+		{
+			name: "implied only",
+			resp: response(`<link href="/wp-content/style.css"><script src="/jquery.min.js"></script>`, "X-Varnish", "1", "Via", "varnish"),
+			want: []Product{
+				{"MySQL", "", 50},
+				{"PHP", "", 100},
+				{"Varnish", "", 100},
+				{"WordPress", "", 100},
+				{"jQuery", "unknown", 100},
+			},
+		},
+		{
+			name: "yaml",
+			resp: response(`<title>Admin console</title>Apache Tomcat/9.0.1`, "x-admin", ""),
+			want: []Product{
+				{"Admin panel", "console", 100},
+				{"Java", "", 100},
+				{"Tomcat", "9.0.1", 100},
+			},
+		},
+		{
+			name: "low confidence",
+			resp: response(`powered by tomcat`),
+			want: []Product{{"Java", "", 30}, {"Tomcat", "", 30}},
+		},
+		{
+			name:     "favicon",
+			resp:     response(""),
+			favicons: []int32{1, 116323821},
+			want:     []Product{{"Favicon only", "", 100}},
+		},
+		{
+			name: "nothing",
+			resp: response("hello", "Server", "Apache"),
+		},
+	} {
+		got := e.Match(tc.resp, tc.favicons...)
+		request.ReleaseResponse(tc.resp)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: got %+v, want %+v", tc.name, got[i], tc.want[i])
+			}
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	rules, err := LoadWappalyzer(strings.NewReader(`{"A": {"html": "one", "implies": ["B", "C"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rules[0]; len(r.Body) != 1 || r.Body[0] != "one" || len(r.Implies) != 2 {
+		t.Errorf("got %+v", r)
+	}
+
+	if _, err := LoadYAML(strings.NewReader("- title: x\n")); err == nil {
+		t.Error("a rule without name was accepted")
+	}
+	if rules, err := LoadYAML(strings.NewReader("")); err != nil || len(rules) != 0 {
+		t.Errorf("got %v, %v from an empty document", rules, err)
+	}
+}
@@ -0,0 +1,125 @@
+// Package fingerprint identifies the products behind a request.Response with
+// Wappalyzer-style rules matching headers, cookies, the title, the body,
+// meta tags, scripts and the favicon hash.
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule identifies one product. Patterns are regular expressions matched
+// case-insensitively, which may be followed by Wappalyzer tags, e.g.
+// `WordPress ([\d.]+)\;version:\1\;confidence:50`. An empty pattern in a
+// map only requires the header, cookie or meta tag to be present.
+type Rule struct {
+	Name    string              `json:"name" yaml:"name"`
+	Headers map[string]Patterns `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Cookies map[string]Patterns `json:"cookies,omitempty" yaml:"cookies,omitempty"`
+	Title   Patterns            `json:"title,omitempty" yaml:"title,omitempty"`
+	Body    Patterns            `json:"body,omitempty" yaml:"body,omitempty"`
+	Meta    map[string]Patterns `json:"meta,omitempty" yaml:"meta,omitempty"`
+	Scripts Patterns            `json:"scripts,omitempty" yaml:"scripts,omitempty"` // src of script elements
+	Favicon []int32             `json:"favicon,omitempty" yaml:"favicon,omitempty"` // see request.Response.FaviconHash
+	// Implies are the names of products the product runs on, they may carry
+	// a confidence tag.
+	Implies Patterns `json:"implies,omitempty" yaml:"implies,omitempty"`
+}
+
+// Patterns is a list of patterns, which may be written as a single string
+type Patterns []string
+
+func (p *Patterns) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*p = Patterns{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*p = l
+	return nil
+}
+
+func (p *Patterns) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = Patterns{value.Value}
+		return nil
+	}
+	var l []string
+	if err := value.Decode(&l); err != nil {
+		return err
+	}
+	*p = l
+	return nil
+}
+
+// LoadYAML reads a list of rules in YAML
+func LoadYAML(r io.Reader) ([]*Rule, error) {
+	var rules []*Rule
+	if err := yaml.NewDecoder(r).Decode(&rules); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not decode rules: %w", err)
+	}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+	}
+	return rules, nil
+}
+
+// technology is a product of the Wappalyzer technologies.json
+type technology struct {
+	Headers   map[string]Patterns `json:"headers"`
+	Cookies   map[string]Patterns `json:"cookies"`
+	HTML      Patterns            `json:"html"`
+	Text      Patterns            `json:"text"`
+	Meta      map[string]Patterns `json:"meta"`
+	ScriptSrc Patterns            `json:"scriptSrc"`
+	Implies   Patterns            `json:"implies"`
+}
+
+// LoadWappalyzer reads rules from a Wappalyzer technologies.json, either
+// the bare object of products or one with a technologies key. Fields not
+// applicable to a single response, like js and dom, are ignored.
+func LoadWappalyzer(r io.Reader) ([]*Rule, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("could not decode rules: %w", err)
+	}
+	if wrapped, ok := raw["technologies"]; ok {
+		raw = nil
+		if err := json.Unmarshal(wrapped, &raw); err != nil {
+			return nil, fmt.Errorf("could not decode rules: %w", err)
+		}
+	}
+
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rules := make([]*Rule, 0, len(names))
+	for _, name := range names {
+		var t technology
+		if err := json.Unmarshal(raw[name], &t); err != nil {
+			return nil, fmt.Errorf("could not decode rule %s: %w", name, err)
+		}
+		rules = append(rules, &Rule{
+			Name:    name,
+			Headers: t.Headers,
+			Cookies: t.Cookies,
+			Body:    append(t.HTML, t.Text...),
+			Meta:    t.Meta,
+			Scripts: t.ScriptSrc,
+			Implies: t.Implies,
+		})
+	}
+	return rules, nil
+}
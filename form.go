@@ -0,0 +1,115 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// SubmitForm prepares r to submit the form of resp matched by formSelector
+// as a browser would, the first form if formSelector is empty. A selector
+// matching an element inside a form selects that form. The values of the
+// form, hidden inputs included, are sent with overrides applied on top,
+// which replace the value of a control or add one. The action is resolved
+// against the URL of resp, and the body is multipart if the form's enctype
+// says so. Cookies come from the Jar or the Session of r, so reusing the
+// request which fetched resp keeps the session. Errors are returned by Do.
+func (r *Request) SubmitForm(resp *Response, formSelector string, overrides Data) *Request {
+	if formSelector == "" {
+		formSelector = "form"
+	}
+	form, action, err := resp.form(formSelector)
+	if err != nil {
+		r.fail(err)
+		return r
+	}
+
+	values := form.Values()
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values = override(values, k, overrides[k])
+	}
+
+	if form.Method != MethodPost {
+		r.Method(MethodGet).prepare(action)
+		return r.SetParamList(ParamList(values))
+	}
+	r.Method(MethodPost).prepare(action)
+	if strings.EqualFold(strings.TrimSpace(form.Enctype), "multipart/form-data") {
+		return r.multipartValues(values)
+	}
+	return r.SetDataList(values)
+}
+
+// form returns the form matched by selector and its resolved action
+func (r *Response) form(selector string) (*Form, string, error) {
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, "", err
+	}
+	doc, err := r.Document()
+	if err != nil {
+		return nil, "", err
+	}
+	n := sel.MatchFirst(doc)
+	for n != nil && !(n.Type == html.ElementNode && n.Data == "form") {
+		n = n.Parent
+	}
+	if n == nil {
+		return nil, "", fmt.Errorf("no form matches %q", selector)
+	}
+	form := parseForm(n)
+
+	base, err := r.base(doc)
+	if err != nil {
+		return nil, "", err
+	}
+	action, err := base.Parse(strings.TrimSpace(form.Action))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not parse form action: %w", err)
+	}
+	action.Fragment = ""
+	return &form, action.String(), nil
+}
+
+// override sets the value of the first control named k to v and drops the
+// others of that name, or appends k if there is none.
+func override(values DataList, k, v string) DataList {
+	out := values[:0]
+	found := false
+	for _, kv := range values {
+		if kv.Key != k {
+			out = append(out, kv)
+		} else if !found {
+			out = append(out, Pair{Key: k, Value: v})
+			found = true
+		}
+	}
+	if !found {
+		out = append(out, Pair{Key: k, Value: v})
+	}
+	return out
+}
+
+// multipartValues sets the body to values encoded as multipart/form-data
+func (r *Request) multipartValues(values DataList) *Request {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for _, kv := range values {
+		_ = w.WriteField(kv.Key, kv.Value)
+	}
+	_ = w.Close()
+
+	r.Request.SetBodyRaw(b.Bytes())
+	r.Request.Header.SetMultipartFormBoundary(w.Boundary())
+	return r
+}
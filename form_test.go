@@ -0,0 +1,54 @@
+package request
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubmitForm(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			b, _ := io.ReadAll(r.Body)
+			w.Write([]byte(r.URL.Path + "?" + string(b)))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<form id="login" action="login" method="post">
+			<input type="hidden" name="token" value="t1">
+			<input name="user" value="">
+		</form>`))
+	}))
+	defer srv.Close()
+
+	req, page := AcquireRequestResponse()
+	defer ReleaseRequest(req)
+	defer ReleaseResponse(page)
+	if err := req.Get(srv.URL + "/app/").Do(page); err != nil {
+		t.Fatal(err)
+	}
+	resp := AcquireResponse()
+	defer ReleaseResponse(resp)
+
+	if err := req.SubmitForm(page, "#login", Data{"user": "admin"}).Do(resp); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Text(), "/app/login?token=t1&user=admin"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// the first error wins over a later successful builder call
+	err := req.SubmitForm(page, "#missing", nil).SubmitForm(page, "#login", nil).Do(resp)
+	if err == nil || !strings.Contains(err.Error(), "no form matches") {
+		t.Errorf("got %v, want the missing form error", err)
+	}
+	// but it doesn't outlive the Do which returned it
+	if err := req.SubmitForm(page, "#login", Data{"user": "guest"}).Do(resp); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resp.Text(), "/app/login?token=t1&user=guest"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
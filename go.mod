@@ -4,12 +4,19 @@ go 1.20
 
 require (
 	github.com/12end/tls v0.0.0-20230329031950-bbfc948c6240
+	github.com/andybalholm/brotli v1.0.5
+	github.com/andybalholm/cascadia v1.3.1
+	github.com/antchfx/htmlquery v1.3.0
+	github.com/klauspost/compress v1.16.3
 	github.com/valyala/fasthttp v1.46.0
+	golang.org/x/net v0.8.0
+	golang.org/x/text v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/andybalholm/brotli v1.0.5 // indirect
-	github.com/klauspost/compress v1.16.3 // indirect
+	github.com/antchfx/xpath v1.2.4 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	golang.org/x/crypto v0.7.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
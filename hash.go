@@ -0,0 +1,221 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/valyala/fasthttp"
+)
+
+var iconSelector = cascadia.MustCompile("link[rel][href]")
+
+// FaviconHash returns the hash Shodan and FOFA index favicons by, the
+// murmur3 hash of the body encoded in base64 with a newline every 76
+// characters and at the end.
+func (r *Response) FaviconHash() int32 {
+	b64 := base64.StdEncoding.EncodeToString(r.bodyBytes())
+	var wrapped strings.Builder
+	for len(b64) > 76 {
+		wrapped.WriteString(b64[:76])
+		wrapped.WriteByte('\n')
+		b64 = b64[76:]
+	}
+	wrapped.WriteString(b64)
+	wrapped.WriteByte('\n')
+	return int32(murmur3([]byte(wrapped.String())))
+}
+
+// BodyMD5, BodySHA1 and BodySHA256 return the hex digest of the body with
+// its content encoding removed.
+func (r *Response) BodyMD5() string {
+	sum := md5.Sum(r.bodyBytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Response) BodySHA1() string {
+	sum := sha1.Sum(r.bodyBytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Response) BodySHA256() string {
+	sum := sha256.Sum256(r.bodyBytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// BodySimhash returns the 64 bit simhash of the words of Text, similar pages
+// have hashes a few bits apart, see SimhashDistance.
+func (r *Response) BodySimhash() uint64 {
+	var weights [64]int
+	words := strings.FieldsFunc(strings.ToLower(r.Text()), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	for _, w := range words {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(w))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// SimhashDistance returns the number of bits a and b differ in
+func SimhashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// bodyBytes returns the body with its content encoding removed
+func (r *Response) bodyBytes() []byte {
+	body, err := r.Response.BodyUncompressed()
+	if err != nil {
+		return r.Response.Body()
+	}
+	return body
+}
+
+// FetchFavicon fetches the favicon of the page into icon with r, keeping
+// the settings of r like its proxy, headers and cookies. The icons declared
+// by link elements of page are tried in order, then /favicon.ico, until one
+// answers with a body other than an HTML page.
+func (r *Request) FetchFavicon(page, icon *Response) error {
+	return r.FetchFaviconContext(context.Background(), page, icon)
+}
+
+// FetchFaviconContext is like FetchFavicon but aborts as soon as ctx is done.
+func (r *Request) FetchFaviconContext(ctx context.Context, page, icon *Response) error {
+	var err error
+	for _, u := range page.favicons() {
+		if err = r.Get(u).DoContext(ctx, icon); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+		// single page apps answer every path with their index
+		html := bytes.HasPrefix(bytes.ToLower(icon.Header.ContentType()), []byte("text/html"))
+		if icon.StatusCode() == fasthttp.StatusOK && len(icon.Body()) > 0 && !html {
+			return nil
+		}
+		if html {
+			err = fmt.Errorf("could not fetch favicon %s: got an HTML page", u)
+		} else {
+			err = fmt.Errorf("could not fetch favicon %s: status code: %d", u, icon.StatusCode())
+		}
+	}
+	if err == nil {
+		err = errors.New("no favicon found")
+	}
+	return err
+}
+
+// favicons returns the URLs the favicon of the page may be found at
+func (r *Response) favicons() []string {
+	var urls []string
+	seen := map[string]bool{}
+	add := func(u string) {
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	doc, err := r.Document()
+	if err != nil {
+		return []string{"/favicon.ico"}
+	}
+	base, err := r.base(doc)
+	if err != nil {
+		return []string{"/favicon.ico"}
+	}
+	for _, n := range iconSelector.MatchAll(doc) {
+		rel, _ := attr(n, "rel")
+		href, _ := attr(n, "href")
+		if !isIconRel(rel) || strings.HasPrefix(strings.TrimSpace(href), "data:") {
+			continue
+		}
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			add(u.String())
+		}
+	}
+	if u, err := base.Parse("/favicon.ico"); err == nil {
+		add(u.String())
+	}
+	return urls
+}
+
+// isIconRel reports whether the rel attribute declares an icon, like
+// "icon", "shortcut icon" or "apple-touch-icon".
+func isIconRel(rel string) bool {
+	for _, t := range strings.Fields(strings.ToLower(rel)) {
+		if t == "icon" || strings.HasSuffix(t, "-icon") {
+			return true
+		}
+	}
+	return false
+}
+
+// murmur3 is the 32 bit MurmurHash3 with seed 0, as in Python's mmh3.hash
+func murmur3(data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	var h uint32
+	n := len(data) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch len(data) & 3 {
+	case 3:
+		k ^= uint32(data[n+2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[n+1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[n])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
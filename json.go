@@ -0,0 +1,110 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSON sets the body to v encoded as JSON, with the JSON content type. An
+// encoding error is returned by Do.
+func (r *Request) JSON(v interface{}) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.fail(fmt.Errorf("could not encode JSON body: %w", err))
+		return r
+	}
+	r.Request.SetBodyRaw(b)
+	r.ContentType(ContentTypeJson)
+	return r
+}
+
+// JSON decodes the body of the response into v
+func (r *Response) JSON(v interface{}) error {
+	return json.Unmarshal([]byte(r.Text()), v)
+}
+
+// JSONPath returns the value at path in the JSON body, like gjson does.
+// path is made of keys separated by dots, where a number indexes an array
+// and # is the length of an array, dots in keys are escaped with \.
+// Objects are map[string]interface{}, arrays []interface{} and numbers
+// json.Number. ok is false if the body isn't JSON or path doesn't exist.
+func (r *Response) JSONPath(path string) (value interface{}, ok bool) {
+	if r.json == nil {
+		d := json.NewDecoder(strings.NewReader(r.Text()))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, false
+		}
+		r.json = &v
+	}
+	value = *r.json
+	if path == "" {
+		return value, true
+	}
+	for _, key := range splitPath(path) {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			if value, ok = node[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			if key == "#" {
+				value = json.Number(strconv.Itoa(len(node)))
+				continue
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			value = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// JSONPathString is like JSONPath but returns strings and numbers as they
+// are and other values encoded as JSON.
+func (r *Response) JSONPathString(path string) (string, bool) {
+	value, ok := r.JSONPath(path)
+	if !ok {
+		return "", false
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// splitPath splits path at the dots which aren't escaped
+func splitPath(path string) []string {
+	var (
+		keys []string
+		key  bytes.Buffer
+	)
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '\\' && i+1 < len(path):
+			i++
+			key.WriteByte(path[i])
+		case c == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(c)
+		}
+	}
+	return append(keys, key.String())
+}
@@ -0,0 +1,70 @@
+package request
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONKeepsFirstError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	req, resp := AcquireRequestResponse()
+	defer ReleaseRequest(req)
+	defer ReleaseResponse(resp)
+
+	err := req.Post(srv.URL).JSON(func() {}).JSON(map[string]int{"a": 1}).Do(resp)
+	if err == nil || !strings.Contains(err.Error(), "could not encode JSON body") {
+		t.Fatalf("got %v, want the encoding error", err)
+	}
+	// the error was returned, the request can be built again
+	if err := req.Post(srv.URL).JSON(map[string]int{"a": 1}).Do(resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Text(); got != `{"a":1}` {
+		t.Errorf("got body %q", got)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	resp := AcquireResponse()
+	defer ReleaseResponse(resp)
+	resp.SetBodyString(`{"data": {"items": [{"id": 1}, {"id": 2.50, "tags": ["a"]}], "a.b": "dotted"}, "ok": true}`)
+
+	for _, tc := range []struct {
+		path, want string
+		ok         bool
+	}{
+		{"data.items.#", "2", true},
+		{"data.items.1.id", "2.50", true},
+		{"data.items.0", `{"id":1}`, true},
+		{"data.items.1.tags", `["a"]`, true},
+		{`data.a\.b`, "dotted", true},
+		{"ok", "true", true},
+		{"data.items.2", "", false},
+		{"data.items.-1", "", false},
+		{"data.missing", "", false},
+		{"ok.deeper", "", false},
+	} {
+		got, ok := resp.JSONPathString(tc.path)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %q %v, want %q %v", tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+	if v, ok := resp.JSONPath(""); !ok || v == nil {
+		t.Error("the empty path should return the whole document")
+	}
+
+	invalid := AcquireResponse()
+	defer ReleaseResponse(invalid)
+	invalid.SetBodyString("not json")
+	if _, ok := invalid.JSONPath("a"); ok {
+		t.Error("got a value from a body which isn't JSON")
+	}
+}
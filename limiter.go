@@ -0,0 +1,188 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLimited is returned by a fail-fast Limiter instead of waiting
+var ErrLimited = errors.New("request limit reached")
+
+// Limiter throttles requests per host, ports ignored, and caps the requests
+// in flight overall. It can be shared by Requests and raw clients. The
+// fields must not be changed once it is in use.
+type Limiter struct {
+	Rate        float64 // requests per second to a host, unlimited if zero
+	Burst       int     // requests to a host which may be sent at once within Rate, 1 if zero
+	MaxPerHost  int     // requests in flight to a host, unlimited if zero
+	MaxInFlight int     // requests in flight overall, unlimited if zero
+	FailFast    bool    // fail with ErrLimited instead of waiting
+
+	mu       sync.Mutex
+	hosts    map[string]*hostLimit
+	sweepAt  int // number of hosts the next sweep runs at
+	inFlight chan struct{}
+}
+
+// minSweep is the number of hosts below which they are never swept
+const minSweep = 256
+
+type hostLimit struct {
+	inFlight chan struct{}
+	tokens   float64
+	last     time.Time
+	refs     int // requests waiting or in flight
+}
+
+// Acquire waits until a request to host may be sent and returns the
+// function to call once it is done. It fails with ErrLimited in fail-fast
+// mode, or with an error wrapping ctx.Err() if ctx is done first. The rate
+// is waited for before the in-flight slots are taken, so requests held back
+// by the rate of their host don't keep the others from being sent.
+func (l *Limiter) Acquire(ctx context.Context, host string) (release func(), err error) {
+	global, h := l.limits(canonicalHost(host))
+	var held []chan struct{}
+	release = func() {
+		for _, ch := range held {
+			<-ch
+		}
+		l.mu.Lock()
+		h.refs--
+		l.mu.Unlock()
+	}
+	if err := l.wait(ctx, h); err != nil {
+		release()
+		return nil, err
+	}
+	for _, ch := range []chan struct{}{h.inFlight, global} {
+		if ch == nil {
+			continue
+		}
+		if err := l.take(ctx, ch); err != nil {
+			l.refund(h)
+			release()
+			return nil, err
+		}
+		held = append(held, ch)
+	}
+	return release, nil
+}
+
+// limits returns the global and host limits, creating them on first use.
+// The host limit is referenced until the request is released.
+func (l *Limiter) limits(host string) (chan struct{}, *hostLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight == nil && l.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, l.MaxInFlight)
+	}
+	if l.hosts == nil {
+		l.hosts = map[string]*hostLimit{}
+	}
+	h := l.hosts[host]
+	if h == nil {
+		if len(l.hosts) >= l.sweepAt {
+			l.sweep()
+		}
+		h = &hostLimit{tokens: float64(l.burst()), last: time.Now()}
+		if l.MaxPerHost > 0 {
+			h.inFlight = make(chan struct{}, l.MaxPerHost)
+		}
+		l.hosts[host] = h
+	}
+	h.refs++
+	return l.inFlight, h
+}
+
+// sweep forgets the hosts without requests whose bucket is full again, a
+// new limit for them starts out the same. It runs whenever the number of
+// hosts doubled since the last sweep.
+func (l *Limiter) sweep() {
+	now := time.Now()
+	for host, h := range l.hosts {
+		if h.refs == 0 && (l.Rate <= 0 || h.tokens+now.Sub(h.last).Seconds()*l.Rate >= float64(l.burst())) {
+			delete(l.hosts, host)
+		}
+	}
+	l.sweepAt = 2 * len(l.hosts)
+	if l.sweepAt < minSweep {
+		l.sweepAt = minSweep
+	}
+}
+
+// take takes a slot of ch
+func (l *Limiter) take(ctx context.Context, ch chan struct{}) error {
+	if l.FailFast {
+		select {
+		case ch <- struct{}{}:
+			return nil
+		default:
+			return ErrLimited
+		}
+	}
+	select {
+	case ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("limiter wait canceled: %w", ctx.Err())
+	}
+}
+
+// wait waits for a token of the rate of h
+func (l *Limiter) wait(ctx context.Context, h *hostLimit) error {
+	if l.Rate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	h.tokens += now.Sub(h.last).Seconds() * l.Rate
+	if burst := float64(l.burst()); h.tokens > burst {
+		h.tokens = burst
+	}
+	h.last = now
+	if h.tokens >= 1 {
+		h.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+	if l.FailFast {
+		l.mu.Unlock()
+		return ErrLimited
+	}
+	// reserve the token, the wait ends once it has been refilled
+	h.tokens--
+	delay := time.Duration(-h.tokens / l.Rate * float64(time.Second))
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		h.tokens++
+		l.mu.Unlock()
+		return fmt.Errorf("limiter wait canceled: %w", ctx.Err())
+	}
+}
+
+// refund gives back the token of a request which won't be sent
+func (l *Limiter) refund(h *hostLimit) {
+	if l.Rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	h.tokens++
+	l.mu.Unlock()
+}
+
+func (l *Limiter) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return 1
+}
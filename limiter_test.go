@@ -0,0 +1,99 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterRateDoesNotHoldGlobalSlots(t *testing.T) {
+	l := &Limiter{Rate: 1, MaxInFlight: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the first request to a takes its token, the others wait for the rate
+	release, err := l.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, err := l.Acquire(ctx, "a"); err == nil {
+				release()
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	release, err = l.Acquire(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("request to another host waited %v", d)
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestLimiterMaxPerHost(t *testing.T) {
+	l := &Limiter{MaxPerHost: 1, FailFast: true}
+	release, err := l.Acquire(context.Background(), "a:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background(), "A:443"); err != ErrLimited {
+		t.Fatalf("got %v, want ErrLimited", err)
+	}
+	if r, err := l.Acquire(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	} else {
+		r()
+	}
+	release()
+	if r, err := l.Acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	} else {
+		r()
+	}
+}
+
+func TestLimiterFailFastRefundsToken(t *testing.T) {
+	l := &Limiter{Rate: 0.001, Burst: 2, MaxPerHost: 1, FailFast: true}
+	release, err := l.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background(), "a"); err != ErrLimited {
+		t.Fatalf("got %v, want ErrLimited", err)
+	}
+	release()
+	// the second token was given back when the slot wasn't free
+	if r, err := l.Acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	} else {
+		r()
+	}
+}
+
+func TestLimiterSweepsIdleHosts(t *testing.T) {
+	l := &Limiter{MaxPerHost: 1}
+	for i := 0; i < 10*minSweep; i++ {
+		release, err := l.Acquire(context.Background(), fmt.Sprintf("host%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if n := len(l.hosts); n > minSweep {
+		t.Errorf("%d hosts kept, want at most %d", n, minSweep)
+	}
+}
@@ -0,0 +1,131 @@
+package request
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpproxy"
+)
+
+const proxyDialTimeout = 5 * time.Second
+
+var (
+	defaultProxy   string
+	defaultProxyMu sync.RWMutex
+	clients        sync.Map // clientKey -> *fasthttp.Client
+)
+
+type clientKey struct {
+	base        *fasthttp.Client
+	proxy       string
+	trace       bool
+	dialTimeout time.Duration // of the traced dialer replacing fasthttp's
+}
+
+// SetDefaultProxy sets the upstream proxy used by every Request which doesn't
+// set its own one with Request.Proxy. An empty string disables it.
+//
+// Supported formats are http://[user:pass@]host:port and
+// socks5://[user:pass@]host:port, plain host:port is treated as http.
+func SetDefaultProxy(proxy string) {
+	defaultProxyMu.Lock()
+	defaultProxy = proxy
+	defaultProxyMu.Unlock()
+}
+
+// DefaultProxy returns the proxy set by SetDefaultProxy.
+func DefaultProxy() string {
+	defaultProxyMu.RLock()
+	defer defaultProxyMu.RUnlock()
+	return defaultProxy
+}
+
+// Proxy routes this request through the given upstream proxy, overriding
+// the default one. See SetDefaultProxy for the supported formats.
+func (r *Request) Proxy(proxy string) *Request {
+	r.proxy = proxy
+	return r
+}
+
+// getClient returns the client the request should be sent with, which is a
+// cached copy of r.client if the request goes through a proxy or is traced.
+func (r *Request) getClient() *fasthttp.Client {
+	proxy := r.proxy
+	if proxy == "" {
+		proxy = DefaultProxy()
+	}
+	trace := r.Trace != nil
+	if proxy == "" && !trace {
+		return r.client
+	}
+	key := clientKey{base: r.client, proxy: proxy, trace: trace}
+	if trace && proxy == "" && r.client.Dial == nil {
+		// fasthttp bounds its own dial by the timeout of the request
+		key.dialTimeout = fasthttp.DefaultDialTimeout
+		if r.timeout > 0 {
+			key.dialTimeout = r.timeout
+		}
+	}
+	if c, ok := clients.Load(key); ok {
+		return c.(*fasthttp.Client)
+	}
+	dial := r.client.Dial
+	if proxy != "" {
+		dial = proxyDialer(proxy)
+	}
+	if trace {
+		dial = traceDialer(dial, r.client.DialDualStack, key.dialTimeout)
+	}
+	c, _ := clients.LoadOrStore(key, copyClient(r.client, dial))
+	return c.(*fasthttp.Client)
+}
+
+// copyClient copies the configuration of base into a new client which
+// dials with dial.
+func copyClient(base *fasthttp.Client, dial fasthttp.DialFunc) *fasthttp.Client {
+	return &fasthttp.Client{
+		Name:                          base.Name,
+		NoDefaultUserAgentHeader:      base.NoDefaultUserAgentHeader,
+		Dial:                          dial,
+		DialDualStack:                 base.DialDualStack,
+		TLSConfig:                     base.TLSConfig,
+		MaxConnsPerHost:               base.MaxConnsPerHost,
+		MaxIdleConnDuration:           base.MaxIdleConnDuration,
+		MaxConnDuration:               base.MaxConnDuration,
+		MaxIdemponentCallAttempts:     base.MaxIdemponentCallAttempts,
+		ReadBufferSize:                base.ReadBufferSize,
+		WriteBufferSize:               base.WriteBufferSize,
+		ReadTimeout:                   base.ReadTimeout,
+		WriteTimeout:                  base.WriteTimeout,
+		MaxResponseBodySize:           base.MaxResponseBodySize,
+		DisableHeaderNamesNormalizing: base.DisableHeaderNamesNormalizing,
+		DisablePathNormalizing:        base.DisablePathNormalizing,
+		MaxConnWaitTimeout:            base.MaxConnWaitTimeout,
+		RetryIf:                       base.RetryIf,
+		ConnPoolStrategy:              base.ConnPoolStrategy,
+		StreamResponseBody:            base.StreamResponseBody,
+		ConfigureClient:               base.ConfigureClient,
+	}
+}
+
+func proxyDialer(proxy string) fasthttp.DialFunc {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err == nil && strings.HasPrefix(strings.ToLower(u.Scheme), "socks5") {
+		return fasthttpproxy.FasthttpSocksDialer(proxy)
+	}
+	if err == nil {
+		// fasthttpproxy expects [user:pass@]host:port
+		proxy = u.Host
+		if u.User != nil {
+			password, _ := u.User.Password()
+			proxy = u.User.Username() + ":" + password + "@" + proxy
+		}
+	}
+	return fasthttpproxy.FasthttpHTTPDialerTimeout(proxy, proxyDialTimeout)
+}
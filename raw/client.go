@@ -1,12 +1,15 @@
 package raw
 
 import (
-	"fmt"
+	"bytes"
+	"context"
 	"io"
 	"net/http"
 	stdurl "net/url"
 	"strings"
 	"time"
+
+	"github.com/12end/request/raw/client"
 )
 
 // Client is a client for making raw http requests with go
@@ -53,34 +56,43 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 
 // DoRaw does a raw request with some configuration
 func (c *Client) DoRaw(method, url, uripath string, headers map[string][]string, body io.Reader) (*http.Response, error) {
+	return c.DoRawContext(context.Background(), method, url, uripath, headers, body)
+}
+
+// DoRawContext is like DoRaw but aborts dialing, writing the request and
+// reading the response as soon as ctx is done.
+func (c *Client) DoRawContext(ctx context.Context, method, url, uripath string, headers map[string][]string, body io.Reader) (*http.Response, error) {
 	redirectstatus := &RedirectStatus{
 		FollowRedirects: true,
 		MaxRedirects:    c.Options.MaxRedirects,
 	}
-	return c.do(method, url, uripath, headers, body, redirectstatus, c.Options)
+	return c.do(ctx, method, url, uripath, headers, body, redirectstatus, c.Options)
 }
 
 // DoRawWithOptions performs a raw request with additional options
 func (c *Client) DoRawWithOptions(method, url, uripath string, headers map[string][]string, body io.Reader, options *Options) (*http.Response, error) {
+	return c.DoRawWithOptionsContext(context.Background(), method, url, uripath, headers, body, options)
+}
+
+// DoRawWithOptionsContext is like DoRawWithOptions but aborts dialing, writing
+// the request and reading the response as soon as ctx is done.
+func (c *Client) DoRawWithOptionsContext(ctx context.Context, method, url, uripath string, headers map[string][]string, body io.Reader, options *Options) (*http.Response, error) {
 	redirectstatus := &RedirectStatus{
 		FollowRedirects: options.FollowRedirects,
 		MaxRedirects:    c.Options.MaxRedirects,
 	}
-	return c.do(method, url, uripath, headers, body, redirectstatus, options)
+	return c.do(ctx, method, url, uripath, headers, body, redirectstatus, options)
 }
 
-func (c *Client) getConn(protocol, host string, options *Options) (Conn, error) {
-	var conn Conn
-	var err error
-	if options.Timeout > 0 {
-		conn, err = c.dialer.DialTimeout(protocol, host, options.Timeout, options)
-	} else {
-		conn, err = c.dialer.Dial(protocol, host, options)
-	}
-	return conn, err
+func (c *Client) getConn(ctx context.Context, protocol, host string, options *Options) (Conn, error) {
+	return c.dialer.DialContext(ctx, protocol, host, options.Timeout, options)
 }
 
-func (c *Client) do(method, url, uripath string, headers map[string][]string, body io.Reader, redirectstatus *RedirectStatus, options *Options) (*http.Response, error) {
+func (c *Client) do(ctx context.Context, method, url, uripath string, headers map[string][]string, body io.Reader, redirectstatus *RedirectStatus, options *Options) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
 	protocol := "http"
 	if strings.HasPrefix(strings.ToLower(url), "https://") {
 		protocol = "https"
@@ -126,11 +138,95 @@ func (c *Client) do(method, url, uripath string, headers map[string][]string, bo
 		protocol = "https"
 	}
 
-	conn, err := c.getConn(protocol, host, options)
+	r, resp, err := c.retry(ctx, protocol, host, method, path, headers, body, options)
 	if err != nil {
 		return nil, err
 	}
 
+	if isRedirect(resp.Status.Code) && redirectstatus.FollowRedirects && redirectstatus.Current <= redirectstatus.MaxRedirects {
+		if redirectstatus.via == nil {
+			redirectstatus.via = []*Redirect{{Method: method, URL: url, Headers: headers}}
+		}
+		next, err := nextRedirect(r, resp, method, u, headers, options)
+		if err == nil && next != nil && options.CheckRedirect != nil {
+			err = options.CheckRedirect(next, redirectstatus.via)
+		}
+		switch {
+		case err == ErrUseLastResponse, err == nil && (next == nil || !rewindBody(next, method, body)):
+			return r, nil
+		case err != nil:
+			r.Body.Close()
+			return nil, err
+		}
+		// consume the response body
+		_, err = io.Copy(io.Discard, r.Body)
+		if err := firstErr(err, r.Body.Close()); err != nil {
+			return nil, err
+		}
+		if next.Method != method {
+			body = nil
+			// the next hop has no body to chunk, nor trailers to send after it
+			o := *options
+			o.Chunked, o.ChunkSizes, o.ChunkExtensions, o.Trailers = false, nil, nil, nil
+			options = &o
+		}
+		redirectstatus.Current++
+		redirectstatus.via = append(redirectstatus.via, next)
+		return c.do(ctx, next.Method, next.URL, "", next.Headers, body, redirectstatus, options)
+	}
+
+	return r, err
+}
+
+// retry sends the request until an attempt succeeds or Options.RetryPolicy
+// gives up. A body which can't be rewound is never sent twice.
+func (c *Client) retry(ctx context.Context, protocol, host, method, path string, headers map[string][]string, body io.Reader, options *Options) (*http.Response, *client.Response, error) {
+	for attempt := 1; ; attempt++ {
+		r, resp, err := c.roundTrip(ctx, protocol, host, method, path, headers, body, options, attempt)
+		p := options.RetryPolicy
+		if p == nil || attempt >= p.MaxAttempts {
+			return r, resp, err
+		}
+		var (
+			statusCode int
+			retryAfter string
+		)
+		if err == nil {
+			statusCode = r.StatusCode
+			retryAfter = responseHeader(resp, "Retry-After")
+		}
+		if !p.ShouldRetry(method, statusCode, err) || !rewind(body) {
+			return r, resp, err
+		}
+		if err == nil {
+			_, _ = io.Copy(io.Discard, r.Body)
+			r.Body.Close()
+		}
+		if err := p.Wait(ctx, attempt, retryAfter); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// roundTrip sends the request once over a pooled connection
+func (c *Client) roundTrip(ctx context.Context, protocol, host, method, path string, headers map[string][]string, body io.Reader, options *Options, attempt int) (*http.Response, *client.Response, error) {
+	release := func() {}
+	if options.Limiter != nil {
+		var err error
+		if release, err = options.Limiter.Acquire(ctx, host); err != nil {
+			return nil, nil, err
+		}
+	}
+	start := time.Now()
+	conn, err := c.getConn(ctx, protocol, host, options)
+	if err != nil {
+		release()
+		if options.Trace != nil {
+			startTrace(nil, start, options.Trace, attempt).record(nil)
+		}
+		return nil, nil, err
+	}
+
 	req := toRequest(method, path, nil, headers, body, options)
 	req.AutomaticContentLength = options.AutomaticContentLength
 	req.AutomaticHost = options.AutomaticHostHeader
@@ -139,35 +235,62 @@ func (c *Client) do(method, url, uripath string, headers map[string][]string, bo
 	if options.Timeout > 0 {
 		_ = conn.SetDeadline(time.Now().Add(options.Timeout))
 	}
+	// the watch and the limiter slot last until the body gets closed
+	unwatch := watchContext(ctx, conn)
+	stop := func() {
+		unwatch()
+		release()
+	}
+
+	if cp, ok := conn.(client.Capturer); ok {
+		cp.CaptureResponses(options.CaptureRawBytes)
+	}
+	if l, ok := conn.(client.Lenient); ok {
+		l.SetLenient(options.LenientParsing)
+	}
+	var trace *exchangeTrace
+	if options.Trace != nil {
+		trace = startTrace(conn, start, options.Trace, attempt)
+	}
 
 	if err := conn.WriteRequest(req); err != nil {
-		return nil, err
+		trace.record(nil)
+		stop()
+		conn.Close()
+		return nil, nil, contextErr(ctx, err)
 	}
 	resp, err := conn.ReadResponse(options.ForceReadAllBody)
 	if err != nil {
-		return nil, err
+		trace.record(nil)
+		stop()
+		conn.Close()
+		return nil, nil, contextErr(ctx, err)
 	}
-
-	r, err := toHTTPResponse(conn, resp)
-	if err != nil {
-		return nil, err
+	if resp.Status.Code != client.INFO_SWITCHING_PROTOCOL && !hasBody(method, resp.Status.Code) && !options.ForceReadAllBody {
+		// whatever the headers announce, the connection carries no body
+		resp.Body = bytes.NewReader(nil)
 	}
-
-	if resp.Status.IsRedirect() && redirectstatus.FollowRedirects && redirectstatus.Current <= redirectstatus.MaxRedirects {
-		// consume the response body
-		_, err := io.Copy(io.Discard, r.Body)
-		if err := firstErr(err, r.Body.Close()); err != nil {
-			return nil, err
-		}
-		loc := headerValue(r.Header, "Location")
-		if strings.HasPrefix(loc, "/") {
-			loc = fmt.Sprintf("%s://%s%s", protocol, host, loc)
-		}
-		redirectstatus.Current++
-		return c.do(method, loc, uripath, headers, body, redirectstatus, options)
+	if ctx.Done() != nil {
+		resp.Body = &contextReader{ctx: ctx, Reader: resp.Body}
 	}
+	tracked := &eofReader{Reader: resp.Body}
+	resp.Body = tracked
 
-	return r, err
+	r, err := toHTTPResponse(&bodyCloser{
+		conn:      conn,
+		body:      tracked,
+		keepAlive: keepAlive(req, resp, options),
+		stop:      stop,
+		trace:     trace,
+		resp:      resp,
+	}, resp, options)
+	if err != nil {
+		trace.record(resp)
+		stop()
+		conn.Close()
+		return nil, nil, err
+	}
+	return r, resp, nil
 }
 
 // RedirectStatus is the current redirect status for the request
@@ -175,4 +298,6 @@ type RedirectStatus struct {
 	FollowRedirects bool
 	MaxRedirects    int
 	Current         int
+
+	via []*Redirect // the requests sent so far
 }
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bufio"
+	"io"
+)
+
+// Capturer is implemented by Clients which can keep the bytes of the
+// responses they read as received.
+type Capturer interface {
+	// CaptureResponses keeps up to limit bytes of every following response,
+	// a limit of 0 disables capturing.
+	CaptureResponses(limit int)
+}
+
+// recorder keeps the bytes read from the connection since the start of the
+// current response, up to limit.
+type recorder struct {
+	r     io.Reader
+	limit int
+	buf   []byte
+	base  int64 // stream offset of buf[0]
+	n     int64 // bytes read from r
+}
+
+func (rec *recorder) Read(p []byte) (int, error) {
+	n, err := rec.r.Read(p)
+	if room := rec.limit - len(rec.buf); room > 0 && n > 0 {
+		if room > n {
+			room = n
+		}
+		rec.buf = append(rec.buf, p[:room]...)
+	}
+	rec.n += int64(n)
+	return n, err
+}
+
+// restart drops everything recorded so far, the recording restarts at the
+// current read position of br, which reads from rec.
+func (rec *recorder) restart(br *bufio.Reader) {
+	ahead, _ := br.Peek(br.Buffered())
+	if len(ahead) > rec.limit {
+		ahead = ahead[:rec.limit]
+	}
+	rec.buf = append(rec.buf[:0], ahead...)
+	rec.base = rec.n - int64(br.Buffered())
+}
+
+// bytes returns a copy of the recorded bytes between the stream offsets from and to
+func (rec *recorder) bytes(from, to int64) []byte {
+	end := rec.base + int64(len(rec.buf))
+	if from < rec.base {
+		from = rec.base
+	}
+	if to > end {
+		to = end
+	}
+	if from >= to {
+		return nil
+	}
+	return append([]byte(nil), rec.buf[from-rec.base:to-rec.base]...)
+}
+
+// capture locates a response within the recorded stream
+type capture struct {
+	rec     *recorder
+	br      *bufio.Reader
+	start   int64
+	headEnd int64
+	headSet bool
+
+	frozen     bool
+	head, body []byte
+}
+
+// offset returns the stream offset the parser has read up to
+func (c *capture) offset() int64 {
+	return c.rec.n - int64(c.br.Buffered())
+}
+
+// freeze copies the bytes of the response before the recorder moves on to the next one
+func (c *capture) freeze() {
+	c.head, c.body = c.bytes()
+	c.frozen = true
+}
+
+func (c *capture) bytes() (head, body []byte) {
+	if c.frozen {
+		return c.head, c.body
+	}
+	headEnd := c.headEnd
+	if !c.headSet {
+		headEnd = c.offset()
+	}
+	return c.rec.bytes(c.start, headEnd), c.rec.bytes(headEnd, c.offset())
+}
+
+// endHead marks the current position as the end of the head, c may be nil
+func (c *capture) endHead() {
+	if c != nil {
+		c.headEnd = c.offset()
+		c.headSet = true
+	}
+}
+
+// CaptureResponses keeps up to limit bytes of every following response as
+// received, see Response.RawBytes.
+func (c *client) CaptureResponses(limit int) {
+	c.rec.limit = limit
+}
+
+// startCapture starts recording a new response if capturing is enabled
+func (c *client) startCapture() *capture {
+	if c.last != nil {
+		c.last.freeze()
+		c.last = nil
+	}
+	if c.rec.limit <= 0 {
+		return nil
+	}
+	c.rec.restart(c.reader.Reader)
+	c.last = &capture{rec: c.rec, br: c.reader.Reader}
+	c.last.start = c.last.offset()
+	return c.last
+}
+
+// RawBytes returns the head, that is status line and headers, and the body
+// of the response as received, including any chunk framing and content
+// encoding. They are only kept if the Client was capturing responses when
+// this one was read and are cut at the capture limit. The body holds what
+// has been read of it so far.
+func (r *Response) RawBytes() (head, body []byte) {
+	if r.capture == nil {
+		return nil, nil
+	}
+	return r.capture.bytes()
+}
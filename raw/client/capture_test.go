@@ -0,0 +1,84 @@
+package client
+
+import (
+	"io"
+	"testing"
+)
+
+func TestCaptureResponses(t *testing.T) {
+	const (
+		head1 = "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n"
+		body1 = "2\r\nok\r\n0\r\n\r\n"
+		head2 = "HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\n"
+		body2 = "abc"
+	)
+	c := replay(head1 + body1 + head2 + body2)
+	c.(Capturer).CaptureResponses(1 << 10)
+
+	first, err := c.ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, body := first.RawBytes(); string(head) != head1 || len(body) != 0 {
+		t.Errorf("before reading the body got %q %q", head, body)
+	}
+	if b, _ := io.ReadAll(first.Body); string(b) != "ok" {
+		t.Fatalf("got body %q", b)
+	}
+	second, err := c.ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(second.Body)
+
+	// the first response keeps its bytes once the second one is read
+	for _, tc := range []struct {
+		resp       *Response
+		head, body string
+	}{
+		{first, head1, body1},
+		{second, head2, body2},
+	} {
+		head, body := tc.resp.RawBytes()
+		if string(head) != tc.head || string(body) != tc.body {
+			t.Errorf("got %q %q, want %q %q", head, body, tc.head, tc.body)
+		}
+	}
+}
+
+func TestCaptureLimit(t *testing.T) {
+	c := replay("HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\nabc")
+	c.(Capturer).CaptureResponses(10)
+	resp, err := c.ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(resp.Body)
+	if head, body := resp.RawBytes(); string(head) != "HTTP/1.1 2" || len(body) != 0 {
+		t.Errorf("got %q %q", head, body)
+	}
+}
+
+func TestCaptureHTTP09(t *testing.T) {
+	c := replay("<html>hi</html>")
+	c.(Capturer).CaptureResponses(1 << 10)
+	c.(Lenient).SetLenient(true)
+	resp, err := c.ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.ReadAll(resp.Body)
+	if head, body := resp.RawBytes(); len(head) != 0 || string(body) != "<html>hi</html>" {
+		t.Errorf("got %q %q", head, body)
+	}
+}
+
+func TestCaptureDisabled(t *testing.T) {
+	resp, err := replay("HTTP/1.1 204 No Content\r\n\r\n").ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, body := resp.RawBytes(); head != nil || body != nil {
+		t.Errorf("got %q %q without capturing", head, body)
+	}
+}
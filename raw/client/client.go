@@ -45,6 +45,17 @@ type Request struct {
 	Headers []Header
 
 	Body io.Reader
+
+	// Chunked sends Body with the chunked transfer coding, a Transfer-Encoding
+	// header is added unless Headers has one.
+	Chunked bool
+	// ChunkSizes are the sizes of the chunks in order, the last one repeats
+	ChunkSizes []int
+	// ChunkExtensions are written verbatim after the size of the chunk at
+	// the same index, e.g. ";name=value"
+	ChunkExtensions []string
+	// Trailers are sent after the last chunk
+	Trailers []Header
 }
 
 // ContentLength returns the length of the body. If the body length is not known
@@ -75,15 +86,20 @@ type Client interface {
 
 // NewClient returns a Client implementation which uses rw to communicate.
 func NewClient(rw io.ReadWriter) Client {
+	rec := &recorder{r: rw}
 	return &client{
-		reader: reader{bufio.NewReaderSize(rw, readerBuffer)},
+		reader: reader{bufio.NewReaderSize(rec, readerBuffer)},
 		writer: writer{Writer: rw},
+		rec:    rec,
 	}
 }
 
 type client struct {
 	reader
 	writer
+	rec     *recorder
+	last    *capture // capture of the last response read
+	lenient bool
 }
 
 // SendRequest marshalls a HTTP request to the wire.
@@ -101,6 +117,22 @@ func (c *client) WriteRequest(req *Request) error {
 		}
 	}
 
+	if req.Chunked {
+		if !hasHeader(req.Headers, "Transfer-Encoding") {
+			if err := c.WriteHeader("Transfer-Encoding", "chunked"); err != nil {
+				return err
+			}
+		}
+		if err := c.StartBody(); err != nil {
+			return err
+		}
+		body := req.Body
+		if body == nil {
+			body = strings.NewReader("")
+		}
+		return c.WriteChunked(body, req.ChunkSizes, req.ChunkExtensions, req.Trailers)
+	}
+
 	l := req.ContentLength()
 	if req.AutomaticContentLength {
 		if l >= 0 {
@@ -112,7 +144,9 @@ func (c *client) WriteRequest(req *Request) error {
 
 	if req.Body == nil {
 		// doesn't actually start the body, just sends the terminating \r\n
-		return c.StartBody()
+		err := c.StartBody()
+		c.phase = requestline
+		return err
 	}
 
 	if err := c.StartBody(); err != nil {
@@ -121,12 +155,31 @@ func (c *client) WriteRequest(req *Request) error {
 	return c.WriteBody(req.Body)
 }
 
+func hasHeader(headers []Header, key string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // ReadResponse unmarshalls a HTTP response.
 func (c *client) ReadResponse(forceReadAll bool) (*Response, error) {
-	version, code, msg, err := c.ReadStatusLine()
+	if c.lenient {
+		return c.readResponseLenient(forceReadAll)
+	}
+	capture := c.startCapture()
+	line, err := c.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("ReadStatusLine: %w", err)
+	}
+	// parse the line on its own to keep it as received
+	lr := reader{bufio.NewReader(bytes.NewReader(line))}
+	version, code, msg, err := lr.ReadStatusLine()
 	var headers []Header
 	if err != nil {
-		return nil, fmt.Errorf("ReadStatusLine: %v", err)
+		return nil, fmt.Errorf("ReadStatusLine: %w", err)
 	}
 	for {
 		var key, value string
@@ -142,26 +195,41 @@ func (c *client) ReadResponse(forceReadAll bool) (*Response, error) {
 		}
 		headers = append(headers, Header{key, value})
 	}
+	capture.endHead()
 	var resp = Response{
-		Version: version,
-		Status:  Status{code, msg},
-		Headers: headers,
-		Body:    c.ReadBody(),
+		StatusLine: strings.TrimRight(string(line), "\r\n"),
+		Version:    version,
+		Status:     Status{code, msg},
+		Headers:    headers,
+		Body:       c.ReadBody(),
+		capture:    capture,
 	}
+	c.frameBody(&resp, forceReadAll)
+	return &resp, err
+}
+
+// frameBody limits the body of resp to what its headers announce
+func (c *client) frameBody(resp *Response, forceReadAll bool) {
 	if l := resp.ContentLength(); l >= 0 && !forceReadAll {
 		resp.Body = io.LimitReader(resp.Body, l)
 	} else if resp.TransferEncoding() == "chunked" {
-		resp.Body = httputil.NewChunkedReader(resp.Body)
+		// hand over the bufio.Reader itself, anything else would be wrapped
+		// in a new one which reads past the end of the body
+		resp.Body = &chunkedBody{r: &c.reader, chunks: httputil.NewChunkedReader(c.reader.Reader)}
 	}
-	return &resp, err
 }
 
 // Response represents an RFC2616 response.
 type Response struct {
+	StatusLine string // the status line as received, without the line break
 	Version
 	Status
 	Headers []Header
 	Body    io.Reader
+	// Anomalies describe the deviations from the RFCs tolerated by a lenient Client
+	Anomalies []string
+
+	capture *capture
 }
 
 // ContentLength returns the length of the body. If the body length is not known
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxStatusLineLength = 8 << 10
+	maxHeaderLineLength = 64 << 10
+)
+
+var errLineTooLong = errors.New("line too long")
+
+// Lenient is implemented by Clients which can parse the responses of servers
+// that don't follow the RFCs.
+type Lenient interface {
+	// SetLenient makes following ReadResponse calls accept HTTP/0.9
+	// responses without status line, status lines of other protocols or
+	// with multi-digit versions, bare LF line endings, header lines without
+	// a colon and obs-fold continuation lines. Such deviations are reported
+	// in Response.Anomalies instead of failing the read.
+	SetLenient(lenient bool)
+}
+
+// SetLenient toggles lenient parsing of the following responses
+func (c *client) SetLenient(lenient bool) {
+	c.lenient = lenient
+}
+
+func (c *client) readResponseLenient(forceReadAll bool) (*Response, error) {
+	capture := c.startCapture()
+	resp := &Response{capture: capture}
+
+	line, err := c.readLineLimit(maxStatusLineLength)
+	if len(line) == 0 && err != nil {
+		return nil, fmt.Errorf("ReadStatusLine: %w", err)
+	}
+	version, status, ok := parseStatusLineLenient(line)
+	if !ok {
+		// HTTP/0.9, there is no head and everything is body
+		if capture != nil {
+			capture.headEnd, capture.headSet = capture.start, true
+		}
+		resp.Version = Version{Major: 0, Minor: 9}
+		resp.Status = Status{Code: SUCCESS_OK, Reason: "OK"}
+		resp.Anomalies = append(resp.Anomalies, "no status line, read as HTTP/0.9")
+		resp.Body = io.MultiReader(bytes.NewReader(line), c.ReadBody())
+		return resp, nil
+	}
+	resp.StatusLine = strings.TrimRight(string(line), "\r\n")
+	resp.Version = version
+	resp.Status = status
+	if !strings.HasPrefix(strings.ToUpper(resp.StatusLine), "HTTP/") {
+		resp.Anomalies = append(resp.Anomalies, fmt.Sprintf("non-HTTP status line: %q", resp.StatusLine))
+	}
+	bareLF := !bytes.HasSuffix(line, []byte("\r\n"))
+
+	for {
+		line, err := c.readLineLimit(maxHeaderLineLength)
+		if err != nil {
+			if err == io.EOF {
+				resp.Anomalies = append(resp.Anomalies, "connection closed before the end of the headers")
+				break
+			}
+			return nil, fmt.Errorf("ReadHeader: %w", err)
+		}
+		bareLF = bareLF || !bytes.HasSuffix(line, []byte("\r\n"))
+		text := strings.TrimRight(string(line), "\r\n")
+		if text == "" {
+			break
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(resp.Headers) > 0 {
+			last := &resp.Headers[len(resp.Headers)-1]
+			last.Value = strings.TrimSpace(last.Value + " " + strings.TrimSpace(text))
+			resp.Anomalies = append(resp.Anomalies, fmt.Sprintf("obs-fold continuation of %s: %q", last.Key, text))
+			continue
+		}
+		key, value, found := strings.Cut(text, ":")
+		if !found {
+			resp.Headers = append(resp.Headers, Header{Key: strings.TrimSpace(text)})
+			resp.Anomalies = append(resp.Anomalies, fmt.Sprintf("header line without colon: %q", text))
+			continue
+		}
+		resp.Headers = append(resp.Headers, Header{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)})
+	}
+	if bareLF {
+		resp.Anomalies = append(resp.Anomalies, "bare LF line endings")
+	}
+	capture.endHead()
+
+	resp.Body = c.ReadBody()
+	c.frameBody(resp, forceReadAll)
+	return resp, nil
+}
+
+// parseStatusLineLenient parses status lines like "HTTP/1.1 200 OK",
+// "HTTP/2 200", "HTTP/1.10 200" or "ICY 200 OK". ok is false if line
+// doesn't look like a status line at all.
+func parseStatusLineLenient(line []byte) (version Version, status Status, ok bool) {
+	text := strings.TrimRight(string(line), "\r\n")
+	proto, rest, found := strings.Cut(strings.Replace(text, "\t", " ", 1), " ")
+	if !found || !isProtocol(proto) {
+		return version, status, false
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	digits := 0
+	for digits < len(rest) && digits < maxStatusCodeLength && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 {
+		return version, status, false
+	}
+	code, err := strconv.Atoi(rest[:digits])
+	if err != nil {
+		return version, status, false
+	}
+	status = Status{Code: code, Reason: strings.TrimSpace(rest[digits:])}
+
+	if name, v, found := strings.Cut(proto, "/"); found && strings.EqualFold(name, "HTTP") {
+		major, minor, _ := strings.Cut(v, ".")
+		version.Major, _ = strconv.Atoi(major)
+		version.Minor, _ = strconv.Atoi(minor)
+	}
+	return version, status, true
+}
+
+// isProtocol reports whether s looks like the protocol of a status line,
+// either name/version or an uppercase name like ICY.
+func isProtocol(s string) bool {
+	if s == "" {
+		return false
+	}
+	name, version, found := strings.Cut(s, "/")
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'A' && c <= 'Z' || found && c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	for _, c := range version {
+		if !(c >= '0' && c <= '9' || c == '.') {
+			return false
+		}
+	}
+	return found || len(name) <= 8
+}
+
+// readLineLimit reads a line terminated by \n of at most max bytes.
+func (r *reader) readLineLimit(max int) ([]byte, error) {
+	var line []byte
+	for len(line) < max {
+		frag, err := r.ReadSlice('\n')
+		line = append(line, frag...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return line, err
+	}
+	return line, errLineTooLong
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// replay returns a Client reading the responses from s
+func replay(s string) Client {
+	return NewClient(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(s), io.Discard})
+}
+
+func TestLenientResponses(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		input   string
+		version Version
+		code    int
+		headers []Header
+		body    string
+		anomaly string
+	}{
+		{
+			name:    "http/0.9",
+			input:   "<html>hi</html>\n",
+			version: Version{0, 9},
+			code:    200,
+			body:    "<html>hi</html>\n",
+			anomaly: "no status line, read as HTTP/0.9",
+		},
+		{
+			name:    "icy",
+			input:   "ICY 200 OK\r\nicy-name: radio\r\n\r\nmp3",
+			code:    200,
+			headers: []Header{{"icy-name", "radio"}},
+			body:    "mp3",
+			anomaly: `non-HTTP status line: "ICY 200 OK"`,
+		},
+		{
+			name:    "multi-digit version",
+			input:   "HTTP/1.10 404 Not Found\r\nContent-Length: 0\r\n\r\n",
+			version: Version{1, 10},
+			code:    404,
+			headers: []Header{{"Content-Length", "0"}},
+		},
+		{
+			name:    "bare lf",
+			input:   "HTTP/1.1 200 OK\nContent-Length: 2\n\noknext",
+			version: HTTP_1_1,
+			code:    200,
+			headers: []Header{{"Content-Length", "2"}},
+			body:    "ok",
+			anomaly: "bare LF line endings",
+		},
+		{
+			name:    "obs-fold",
+			input:   "HTTP/1.1 200 OK\r\nX-Long: a\r\n\tb\r\nContent-Length: 0\r\n\r\n",
+			version: HTTP_1_1,
+			code:    200,
+			headers: []Header{{"X-Long", "a b"}, {"Content-Length", "0"}},
+			anomaly: `obs-fold continuation of X-Long: "\tb"`,
+		},
+		{
+			name:    "no colon",
+			input:   "HTTP/1.1 200 OK\r\nbroken header\r\nContent-Length: 0\r\n\r\n",
+			version: HTTP_1_1,
+			code:    200,
+			headers: []Header{{"broken header", ""}, {"Content-Length", "0"}},
+			anomaly: `header line without colon: "broken header"`,
+		},
+	} {
+		c := replay(tc.input)
+		c.(Lenient).SetLenient(true)
+		resp, err := c.ReadResponse(false)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.Version != tc.version || resp.Status.Code != tc.code || string(body) != tc.body {
+			t.Errorf("%s: got %v %d %q", tc.name, resp.Version, resp.Status.Code, body)
+		}
+		if len(resp.Headers) != len(tc.headers) {
+			t.Errorf("%s: got headers %q", tc.name, resp.Headers)
+		} else {
+			for i, h := range tc.headers {
+				if resp.Headers[i] != h {
+					t.Errorf("%s: got header %q, want %q", tc.name, resp.Headers[i], h)
+				}
+			}
+		}
+		found := tc.anomaly == ""
+		for _, a := range resp.Anomalies {
+			found = found || a == tc.anomaly
+		}
+		if !found {
+			t.Errorf("%s: got anomalies %q, want %q", tc.name, resp.Anomalies, tc.anomaly)
+		}
+	}
+}
+
+func TestStrictRejectsHTTP09(t *testing.T) {
+	if _, err := replay("<html>hi</html>\n").ReadResponse(false); err == nil {
+		t.Error("strict parser accepted a response without status line")
+	}
+}
+
+func TestLenientKeepsReadingAfterBareLF(t *testing.T) {
+	c := replay("HTTP/1.1 200 OK\nContent-Length: 1\n\naHTTP/1.1 204 No Content\r\n\r\n")
+	c.(Lenient).SetLenient(true)
+	first, err := c.ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(first.Body); !bytes.Equal(b, []byte("a")) {
+		t.Fatalf("got body %q", b)
+	}
+	second, err := c.ReadResponse(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Status.Code != 204 || len(second.Anomalies) != 0 {
+		t.Errorf("got %d %q", second.Status.Code, second.Anomalies)
+	}
+}
@@ -125,3 +125,28 @@ func (r *reader) ReadBody() io.Reader {
 func (r *reader) readLine() ([]byte, error) {
 	return r.ReadBytes('\n')
 }
+
+// chunkedBody decodes a chunked body and consumes the trailer section after
+// the last chunk, so the reader is left at the start of the next message.
+type chunkedBody struct {
+	r      *reader
+	chunks io.Reader
+	done   bool
+}
+
+func (c *chunkedBody) Read(p []byte) (int, error) {
+	n, err := c.chunks.Read(p)
+	if err == io.EOF && !c.done {
+		c.done = true
+		for {
+			_, _, done, herr := c.r.ReadHeader()
+			if herr != nil {
+				return n, herr
+			}
+			if done {
+				break
+			}
+		}
+	}
+	return n, err
+}
@@ -23,6 +23,7 @@ const (
 	REDIRECTION_NOT_MODIFIED       = 304
 	REDIRECTION_USE_PROXY          = 305
 	REDIRECTION_TEMPORARY_REDIRECT = 307
+	REDIRECTION_PERMANENT_REDIRECT = 308
 
 	CLIENT_ERROR_BAD_REQUEST                     = 400
 	CLIENT_ERROR_UNAUTHORIZED                    = 401
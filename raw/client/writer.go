@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"net/http/httputil"
 	"strings"
 )
 
@@ -37,6 +36,8 @@ func (p *phaseError) Error() string {
 	return fmt.Sprintf("phase error: expected %s, got %s", p.expected, p.got)
 }
 
+const chunkBuffer = 32 << 10
+
 type writer struct {
 	phase
 	io.Writer
@@ -97,15 +98,69 @@ func (w *writer) WriteBody(r io.Reader) error {
 	return err
 }
 
-// WriteChunked writes the contents of r in chunked format to the wire.
-func (w *writer) WriteChunked(r io.Reader) error {
+// WriteChunked writes the contents of r in chunked format to the wire. The
+// chunks are sizes[i] bytes long, the last size repeating, or as long as a
+// single read if sizes is empty. extensions[i] is written verbatim after the
+// size of the i-th chunk, the last chunk included, and trailers follow the
+// last chunk.
+func (w *writer) WriteChunked(r io.Reader, sizes []int, extensions []string, trailers []Header) error {
 	if w.phase != body {
 		return &phaseError{body, w.phase}
 	}
-	cw := httputil.NewChunkedWriter(w)
-	if _, err := io.Copy(cw, r); err != nil {
-		return nil
+	defer func() { w.phase = requestline }()
+
+	bw := bufio.NewWriter(w.Writer)
+	extension := func(i int) string {
+		if i < len(extensions) {
+			return extensions[i]
+		}
+		return ""
 	}
-	w.phase = requestline
-	return cw.Close()
+	buf := make([]byte, chunkBuffer)
+	chunks := 0
+	for {
+		var n int
+		var err error
+		if len(sizes) == 0 {
+			n, err = r.Read(buf)
+		} else {
+			size := sizes[len(sizes)-1]
+			if chunks < len(sizes) {
+				size = sizes[chunks]
+			}
+			if size <= 0 {
+				return fmt.Errorf("invalid chunk size: %d", size)
+			}
+			if size > len(buf) {
+				buf = make([]byte, size)
+			}
+			n, err = io.ReadFull(r, buf[:size])
+		}
+		if n > 0 {
+			fmt.Fprintf(bw, "%x%s\r\n", n, extension(chunks))
+			bw.Write(buf[:n])
+			bw.WriteString(NewLine)
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			chunks++
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(bw, "0%s\r\n", extension(chunks))
+	for _, h := range trailers {
+		if h.Value != "" {
+			fmt.Fprintf(bw, "%s: %s\r\n", h.Key, h.Value)
+		} else {
+			fmt.Fprintf(bw, "%s\r\n", h.Key)
+		}
+	}
+	bw.WriteString(NewLine)
+	return bw.Flush()
 }
@@ -7,6 +7,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/12end/request/raw/client"
@@ -18,59 +19,212 @@ type Dialer interface {
 	Dial(protocol, addr string, options *Options) (Conn, error)
 	// Dial dials a remote http server with timeout returning a Conn.
 	DialTimeout(protocol, addr string, timeout time.Duration, options *Options) (Conn, error)
+	// DialContext dials a remote http server with timeout returning a Conn,
+	// dialing is aborted once ctx is done.
+	DialContext(ctx context.Context, protocol, addr string, timeout time.Duration, options *Options) (Conn, error)
 }
 
 type dialer struct {
-	sync.Mutex                   // protects following fields
-	conns      map[string][]Conn // maps addr to a, possibly empty, slice of existing Conns
+	sync.Mutex                    // protects following fields
+	conns      map[string][]*conn // maps pool key to a, possibly empty, slice of idle Conns
+
+	dials     uint64
+	reuses    uint64
+	discarded uint64
 }
 
 func (d *dialer) Dial(protocol, addr string, options *Options) (Conn, error) {
-	return d.dialTimeout(protocol, addr, 0, options)
+	return d.dialTimeout(context.Background(), protocol, addr, 0, options)
 }
 
 func (d *dialer) DialTimeout(protocol, addr string, timeout time.Duration, options *Options) (Conn, error) {
-	return d.dialTimeout(protocol, addr, timeout, options)
+	return d.dialTimeout(context.Background(), protocol, addr, timeout, options)
+}
+
+func (d *dialer) DialContext(ctx context.Context, protocol, addr string, timeout time.Duration, options *Options) (Conn, error) {
+	return d.dialTimeout(ctx, protocol, addr, timeout, options)
+}
+
+func (d *dialer) dialTimeout(ctx context.Context, protocol, addr string, timeout time.Duration, options *Options) (Conn, error) {
+	addr = addrWithPort(protocol, addr)
+	key := poolKey(protocol, addr, options)
+	if !options.DisableKeepAlives {
+		if c := d.get(key, options.idleConnTimeout()); c != nil {
+			return c, nil
+		}
+	}
+
+	c, timings, err := clientDial(ctx, protocol, addr, timeout, options)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	atomic.AddUint64(&d.dials, 1)
+	tracer := &connTracer{Conn: c}
+	return &conn{
+		Client:  client.NewClient(tracer),
+		Conn:    c,
+		dialer:  d,
+		key:     key,
+		maxIdle: options.maxIdleConnsPerHost(),
+		tracer:  tracer,
+		timings: timings,
+	}, nil
 }
 
-func (d *dialer) dialTimeout(protocol, addr string, timeout time.Duration, options *Options) (Conn, error) {
+// get returns a live idle connection for key, or nil if there is none.
+func (d *dialer) get(key string, idleTimeout time.Duration) *conn {
+	for {
+		d.Lock()
+		conns := d.conns[key]
+		if len(conns) == 0 {
+			d.Unlock()
+			return nil
+		}
+		// most recently used first, it's the least likely to be timed out by the server
+		c := conns[len(conns)-1]
+		conns[len(conns)-1] = nil
+		d.conns[key] = conns[:len(conns)-1]
+		d.Unlock()
+
+		if time.Since(c.idleAt) > idleTimeout || !isAlive(c.Conn) {
+			atomic.AddUint64(&d.discarded, 1)
+			c.Conn.Close()
+			continue
+		}
+		atomic.AddUint64(&d.reuses, 1)
+		c.reused = true
+		return c
+	}
+}
+
+// put adds c to the idle connections, closing it if the pool for its key is full.
+func (d *dialer) put(c *conn) {
 	d.Lock()
 	if d.conns == nil {
-		d.conns = make(map[string][]Conn)
+		d.conns = make(map[string][]*conn)
 	}
-	if c, ok := d.conns[addr]; ok {
-		if len(c) > 0 {
-			conn := c[0]
-			c[0] = c[len(c)-1]
-			d.Unlock()
-			return conn, nil
+	if len(d.conns[c.key]) >= c.maxIdle {
+		d.Unlock()
+		atomic.AddUint64(&d.discarded, 1)
+		c.Conn.Close()
+		return
+	}
+	c.idleAt = time.Now()
+	d.conns[c.key] = append(d.conns[c.key], c)
+	d.Unlock()
+}
+
+// closeIdle closes all idle connections.
+func (d *dialer) closeIdle() {
+	d.Lock()
+	conns := d.conns
+	d.conns = nil
+	d.Unlock()
+	for _, cs := range conns {
+		for _, c := range cs {
+			c.Conn.Close()
 		}
 	}
+}
+
+func (d *dialer) stats() PoolStats {
+	d.Lock()
+	var idle int
+	for _, cs := range d.conns {
+		idle += len(cs)
+	}
 	d.Unlock()
-	c, err := clientDial(protocol, addr, timeout, options)
-	return &conn{
-		Client: client.NewClient(c),
-		Conn:   c,
-		dialer: d,
-	}, err
+	return PoolStats{
+		Dials:     atomic.LoadUint64(&d.dials),
+		Reuses:    atomic.LoadUint64(&d.reuses),
+		Discarded: atomic.LoadUint64(&d.discarded),
+		Idle:      idle,
+	}
 }
 
-func clientDial(protocol, addr string, timeout time.Duration, options *Options) (net.Conn, error) {
+// poolKey identifies connections which can be used interchangeably
+func poolKey(protocol, addr string, options *Options) string {
+	return protocol + "://" + addr + "|" + options.SNI + "|" + options.Proxy
+}
+
+func clientDial(ctx context.Context, protocol, addr string, timeout time.Duration, options *Options) (net.Conn, dialTimings, error) {
+	var (
+		c       net.Conn
+		timings dialTimings
+		err     error
+	)
+	if options.Proxy != "" {
+		proxyTimeout := options.ProxyDialTimeout
+		if proxyTimeout <= 0 {
+			proxyTimeout = timeout
+		}
+		start := time.Now()
+		c, err = proxyDial(ctx, options.Proxy, addr, proxyTimeout)
+		timings.connect = time.Since(start)
+	} else {
+		c, err = directDial(ctx, addr, timeout, &timings)
+	}
+	if err != nil {
+		return nil, timings, err
+	}
 
 	// http
 	if protocol == "http" {
-		if timeout > 0 {
-			return net.DialTimeout("tcp", addr, timeout)
-		}
-		return net.Dial("tcp", addr)
+		return c, timings, nil
 	}
 
 	// https
-	tlsConfig := &tls.Config{InsecureSkipVerify: true}
-	if options.SNI != "" {
-		tlsConfig.ServerName = options.SNI
+	serverName := options.SNI
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(addr)
+	}
+	start := time.Now()
+	c, err = tlsHandshake(ctx, c, serverName, timeout)
+	timings.tlsHandshake = time.Since(start)
+	return c, timings, err
+}
+
+// directDial resolves the host of addr and connects to its addresses in
+// turn, timing both steps.
+func directDial(ctx context.Context, addr string, timeout time.Duration, timings *dialTimings) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	return tls.Dial("tcp", addr, tlsConfig)
+
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	timings.dns = time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	defer func() { timings.connect = time.Since(start) }()
+	d := &net.Dialer{}
+	for _, ip := range ips {
+		var c net.Conn
+		if c, err = d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port)); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// addrWithPort appends the default port of protocol to addr if it has none
+func addrWithPort(protocol, addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	if protocol == "https" {
+		return net.JoinHostPort(strings.Trim(addr, "[]"), "443")
+	}
+	return net.JoinHostPort(strings.Trim(addr, "[]"), "80")
 }
 
 // TlsHandshake tls handshake on a plain connection
@@ -80,21 +234,19 @@ func TlsHandshake(conn net.Conn, addr string, timeout time.Duration) (net.Conn,
 		colonPos = len(addr)
 	}
 	hostname := addr[:colonPos]
+	return tlsHandshake(context.Background(), conn, hostname, timeout)
+}
 
-	var (
-		ctx    context.Context
-		cancel context.CancelFunc
-	)
+func tlsHandshake(ctx context.Context, conn net.Conn, serverName string, timeout time.Duration) (net.Conn, error) {
 	if timeout > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
-	} else {
-		ctx = context.Background()
 	}
 
 	tlsConn := tls.Client(conn, &tls.Config{
 		InsecureSkipVerify: true,
-		ServerName:         hostname,
+		ServerName:         serverName,
 	})
 	if err := tlsConn.HandshakeContext(ctx); err != nil {
 		conn.Close()
@@ -118,11 +270,44 @@ type conn struct {
 	client.Client
 	net.Conn
 	*dialer
+
+	key     string
+	maxIdle int
+	idleAt  time.Time
+	reused  bool
+
+	tracer  *connTracer
+	timings dialTimings
 }
 
+// Release puts the connection back into the pool of its dialer. It must only
+// be called once the previous response has been read completely, connections
+// with pending data are closed instead.
 func (c *conn) Release() {
-	c.dialer.Lock()
-	defer c.dialer.Unlock()
-	addr := c.Conn.RemoteAddr().String()
-	c.dialer.conns[addr] = append(c.dialer.conns[addr], c)
+	if b, ok := c.Client.(interface{ Buffered() int }); ok && b.Buffered() > 0 {
+		atomic.AddUint64(&c.dialer.discarded, 1)
+		c.Conn.Close()
+		return
+	}
+	_ = c.Conn.SetDeadline(time.Time{})
+	c.dialer.put(c)
+}
+
+// CaptureResponses keeps up to limit bytes of the following responses as received
+func (c *conn) CaptureResponses(limit int) {
+	if cp, ok := c.Client.(client.Capturer); ok {
+		cp.CaptureResponses(limit)
+	}
+}
+
+// SetLenient toggles lenient parsing of the following responses
+func (c *conn) SetLenient(lenient bool) {
+	if l, ok := c.Client.(client.Lenient); ok {
+		l.SetLenient(lenient)
+	}
+}
+
+// Close closes the underlying network connection
+func (c *conn) Close() error {
+	return c.Conn.Close()
 }
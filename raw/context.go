@@ -0,0 +1,66 @@
+package raw
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// aLongTimeAgo is a deadline in the past, setting it on a conn unblocks
+// any pending read or write immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+type deadliner interface {
+	SetDeadline(time.Time) error
+}
+
+// watchContext unblocks all io on c once ctx is done. The returned func
+// stops watching and must be called when c is no longer used with ctx.
+func watchContext(ctx context.Context, c deadliner) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			_ = c.SetDeadline(aLongTimeAgo)
+		case <-done:
+		}
+	}()
+	// wait for the watcher so it can't touch a connection which went
+	// back to the pool
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// contextErr returns the context error in favor of err if ctx is done,
+// since err is then most likely caused by watchContext.
+func contextErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("raw request aborted: %w", ctxErr)
+	}
+	return err
+}
+
+// contextReader translates read errors caused by a done context
+type contextReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = contextErr(r.ctx, err)
+	}
+	return n, err
+}
@@ -0,0 +1,103 @@
+package raw
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// contentCodings returns the content codings of a response in the order they
+// were applied, e.g. "gzip, br" for a body compressed with gzip then brotli.
+func contentCodings(headers map[string][]string) []string {
+	var codings []string
+	for k, values := range headers {
+		if !strings.EqualFold(k, "Content-Encoding") {
+			continue
+		}
+		for _, v := range values {
+			for _, coding := range strings.Split(v, ",") {
+				coding = strings.ToLower(strings.TrimSpace(coding))
+				if coding != "" && coding != "identity" {
+					codings = append(codings, coding)
+				}
+			}
+		}
+	}
+	return codings
+}
+
+// decompress wraps body with decoders for codings, undoing the last applied
+// one first. The returned closers release the decoders. Bodies with unknown
+// codings are returned as they are.
+func decompress(body io.Reader, codings []string) (io.Reader, []io.Closer, error) {
+	for _, coding := range codings {
+		switch coding {
+		case "gzip", "x-gzip", "deflate", "br", "zstd":
+		default:
+			return body, nil, nil
+		}
+	}
+	var closers []io.Closer
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			r := body
+			body = &lazyReader{init: func() (io.Reader, error) {
+				return gzip.NewReader(r)
+			}}
+		case "deflate":
+			body = newDeflateReader(body)
+		case "br":
+			body = brotli.NewReader(body)
+		case "zstd":
+			d, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, closers, err
+			}
+			closers = append(closers, d.IOReadCloser())
+			body = d
+		}
+	}
+	return body, closers, nil
+}
+
+// newDeflateReader decodes "deflate" bodies, which are supposed to be zlib
+// streams but are sent as raw deflate data by quite some servers.
+func newDeflateReader(r io.Reader) io.Reader {
+	return &lazyReader{init: func() (io.Reader, error) {
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		// zlib header: CM 8 and a header checksum divisible by 31
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}}
+}
+
+// lazyReader creates its reader on the first read, so that decoders reading
+// a header don't fail on empty bodies before anyone reads them.
+type lazyReader struct {
+	init func() (io.Reader, error)
+	r    io.Reader
+	err  error
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.r == nil && l.err == nil {
+		l.r, l.err = l.init()
+	}
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.r.Read(p)
+}
@@ -0,0 +1,90 @@
+package raw
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const plainBody = "hello world, hello world, hello world"
+
+func encode(t *testing.T, coding string, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	gzipped := encode(t, "gzip", []byte(plainBody))
+	for _, tc := range []struct {
+		name     string
+		encoding string
+		body     []byte
+		disable  bool
+		want     []byte
+		decoded  bool
+	}{
+		{"gzip then br", "gzip, Br", encode(t, "br", gzipped), false, []byte(plainBody), true},
+		{"uppercase", "GZIP", gzipped, false, []byte(plainBody), true},
+		{"x-gzip", "x-gzip", gzipped, false, []byte(plainBody), true},
+		{"zlib deflate", "deflate", encode(t, "zlib", []byte(plainBody)), false, []byte(plainBody), true},
+		{"raw deflate", "deflate", encode(t, "flate", []byte(plainBody)), false, []byte(plainBody), true},
+		{"zstd", "zstd", encode(t, "zstd", []byte(plainBody)), false, []byte(plainBody), true},
+		{"identity", "identity", []byte(plainBody), false, []byte(plainBody), false},
+		{"unknown", "gzip, compress", gzipped, false, gzipped, false},
+		{"disabled", "gzip", gzipped, true, gzipped, false},
+	} {
+		s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+			fmt.Fprintf(w, "HTTP/1.1 200 OK\r\nContent-Encoding: %s\r\nContent-Length: %d\r\n\r\n%s", tc.encoding, len(tc.body), tc.body)
+			return false
+		})
+		options := testOptions()
+		options.DisableDecompression = tc.disable
+		r, err := NewClient(options).DoRaw("GET", s.URL, "", nil, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		// the headers as received are kept by Response
+		resp, err := NewResponse(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := resp.Body(); !bytes.Equal(got, tc.want) {
+			t.Errorf("%s: got body %q", tc.name, got)
+		}
+		if v, _ := resp.GetHeader("Content-Encoding"); v != tc.encoding {
+			t.Errorf("%s: Response kept Content-Encoding %q", tc.name, v)
+		}
+
+		_, hasEncoding := r.Header["Content-Encoding"]
+		_, hasLength := r.Header["Content-Length"]
+		if r.Uncompressed != tc.decoded || hasEncoding == tc.decoded || hasLength == tc.decoded {
+			t.Errorf("%s: got Uncompressed %v with headers %v", tc.name, r.Uncompressed, r.Header)
+		}
+	}
+}
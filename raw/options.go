@@ -3,6 +3,7 @@ package raw
 import (
 	"time"
 
+	"github.com/12end/request"
 	"github.com/12end/request/raw/client"
 )
 
@@ -19,6 +20,47 @@ type Options struct {
 	Proxy                  string
 	ProxyDialTimeout       time.Duration
 	SNI                    string
+	DisableKeepAlives      bool           // closes the connection after every request
+	MaxIdleConnsPerHost    int            // idle connections kept per scheme, host and SNI, 4 if zero
+	IdleConnTimeout        time.Duration  // how long a connection may stay idle, 30s if zero
+	DisableDecompression   bool           // returns the body as sent, whatever its Content-Encoding
+	CaptureRawBytes        int            // keeps up to n bytes of the response as received, see Response.RawBytes
+	LenientParsing         bool           // tolerates malformed responses, see Response.Anomalies
+	Chunked                bool           // sends the body with the chunked transfer coding
+	ChunkSizes             []int          // sizes of the chunks in order, the last one repeats
+	ChunkExtensions        []string       // written verbatim after the size of the chunk at the same index
+	Trailers               client.Headers // sent after the last chunk
+	// Trace gets a TraceInfo appended for every exchange once its response
+	// body is closed, it must not be shared by concurrent requests.
+	Trace *[]request.TraceInfo
+	// CheckRedirect is called before following a redirect with the next hop,
+	// which it may rewrite, and the requests sent so far, oldest first. An
+	// error stops the redirects and is returned, unless it is
+	// ErrUseLastResponse which returns the redirect response instead.
+	CheckRedirect func(next *Redirect, via []*Redirect) error
+	// PreserveMethodOnRedirect resends the method and body on every redirect,
+	// instead of switching to GET on 303 and on 301 or 302 after a POST
+	PreserveMethodOnRedirect bool
+	// RetryPolicy retries failed attempts, every attempt is traced. Bodies
+	// which don't implement io.Seeker are never retried.
+	RetryPolicy *request.RetryPolicy
+	// Limiter throttles every attempt and redirect hop, a request is in
+	// flight until its body is closed
+	Limiter *request.Limiter
+}
+
+func (o *Options) maxIdleConnsPerHost() int {
+	if o.MaxIdleConnsPerHost > 0 {
+		return o.MaxIdleConnsPerHost
+	}
+	return defaultMaxIdleConnsPerHost
+}
+
+func (o *Options) idleConnTimeout() time.Duration {
+	if o.IdleConnTimeout > 0 {
+		return o.IdleConnTimeout
+	}
+	return defaultIdleConnTimeout
 }
 
 // DefaultOptions is the default configuration options for the client
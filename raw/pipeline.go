@@ -0,0 +1,135 @@
+package raw
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	stdurl "net/url"
+	"strings"
+	"time"
+
+	"github.com/12end/request/raw/client"
+)
+
+// Pipeline sends requests back to back over a single new connection to url
+// and reads the responses in order once all of them are written. The
+// requests are written as they are, so Version, Host and Content-Length
+// must be set by the caller. If the server closes the connection or sends
+// garbage early, the responses read so far are returned along with the error.
+func (c *Client) Pipeline(url string, requests []*client.Request) ([]*http.Response, error) {
+	return c.PipelineContext(context.Background(), url, requests)
+}
+
+// PipelineContext is like Pipeline but aborts as soon as ctx is done.
+func (c *Client) PipelineContext(ctx context.Context, url string, requests []*client.Request) ([]*http.Response, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+	u, err := stdurl.ParseRequestURI(url)
+	if err != nil {
+		return nil, err
+	}
+	protocol := "http"
+	if strings.EqualFold(u.Scheme, "https") {
+		protocol = "https"
+	}
+
+	if c.Options.Limiter != nil {
+		release, err := c.Options.Limiter.Acquire(ctx, u.Host)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	// pipelined connections are never shared with the pool, leftovers of a
+	// desync must not leak into other requests
+	options := *c.Options
+	options.DisableKeepAlives = true
+	conn, err := c.getConn(ctx, protocol, u.Host, &options)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if options.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(options.Timeout))
+	}
+	stop := watchContext(ctx, conn)
+	defer stop()
+	if cp, ok := conn.(client.Capturer); ok {
+		cp.CaptureResponses(options.CaptureRawBytes)
+	}
+	if l, ok := conn.(client.Lenient); ok {
+		l.SetLenient(options.LenientParsing)
+	}
+
+	// serialize everything first so the requests leave in as few packets as possible
+	var buf bytes.Buffer
+	w := client.NewClient(&buf)
+	for i, req := range requests {
+		if err := w.WriteRequest(req); err != nil {
+			return nil, fmt.Errorf("could not write request %d: %w", i+1, err)
+		}
+	}
+	if err := conn.WriteRequest(&client.Request{RawBytes: buf.Bytes()}); err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	responses := make([]*http.Response, 0, len(requests))
+	for i, req := range requests {
+		resp, closed, err := readPipelined(conn, req)
+		if err != nil {
+			return responses, fmt.Errorf("could not read response %d: %w", i+1, contextErr(ctx, err))
+		}
+		r, err := toHTTPResponse(nopCloser{}, resp, c.Options)
+		if err != nil {
+			return responses, fmt.Errorf("could not read response %d: %w", i+1, err)
+		}
+		responses = append(responses, r)
+		if closed && i < len(requests)-1 {
+			return responses, fmt.Errorf("connection closed after response %d of %d", i+1, len(requests))
+		}
+	}
+	return responses, nil
+}
+
+// readPipelined reads the final response to req and buffers its body, so the
+// next response can be read from the connection. closed reports whether the
+// server ends the connection after this response.
+func readPipelined(conn Conn, req *client.Request) (resp *client.Response, closed bool, err error) {
+	for {
+		resp, err = conn.ReadResponse(false)
+		if err != nil {
+			return nil, false, err
+		}
+		if resp.Status.IsInformational() && resp.Status.Code != client.INFO_SWITCHING_PROTOCOL {
+			// interim response, the final one follows
+			continue
+		}
+		if !hasBody(req.Method, resp.Status.Code) {
+			resp.Body = bytes.NewReader(nil)
+			return resp, resp.CloseRequested(), nil
+		}
+		closed = resp.ContentLength() < 0 && resp.TransferEncoding() != "chunked"
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, false, err
+		}
+		resp.Body = bytes.NewReader(b)
+		return resp, closed || resp.CloseRequested(), nil
+	}
+}
+
+// hasBody reports whether a response to method with the status code carries a body
+func hasBody(method string, code int) bool {
+	if strings.EqualFold(method, "HEAD") {
+		return false
+	}
+	return !(code >= 100 && code < 200) && code != client.SUCCESS_NO_CONTENT && code != client.REDIRECTION_NOT_MODIFIED
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
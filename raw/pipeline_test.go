@@ -0,0 +1,87 @@
+package raw
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/12end/request/raw/client"
+)
+
+func pipelined(method string, paths ...string) []*client.Request {
+	var requests []*client.Request
+	for _, p := range paths {
+		requests = append(requests, &client.Request{
+			Method:  method,
+			Path:    p,
+			Version: client.HTTP_1_1,
+			Headers: []client.Header{{Key: "Host", Value: "x"}},
+		})
+	}
+	return requests
+}
+
+func TestPipelineReadsResponsesInOrder(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		if req.URL.Path == "/continue" {
+			io.WriteString(w, "HTTP/1.1 100 Continue\r\n\r\n")
+		}
+		io.WriteString(w, "HTTP/1.1 200 OK\r\nContent-Length: "+strconv.Itoa(len(req.URL.Path))+"\r\n\r\n")
+		if req.Method != "HEAD" {
+			io.WriteString(w, req.URL.Path)
+		}
+		return false
+	})
+	requests := append(pipelined("GET", "/a", "/continue"), pipelined("HEAD", "/head")...)
+	requests = append(requests, pipelined("GET", "/b")...)
+
+	responses, err := NewClient(testOptions()).Pipeline(s.URL, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, resp := range responses {
+		b, _ := io.ReadAll(resp.Body)
+		got = append(got, string(b))
+	}
+	if want := "/a,/continue,,/b"; strings.Join(got, ",") != want {
+		t.Errorf("got bodies %q, want %s", got, want)
+	}
+	if n := s.accepted(); n != 1 {
+		t.Errorf("%d connections dialed, want 1", n)
+	}
+}
+
+func TestPipelineEarlyClose(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header string
+		err    string
+	}{
+		{"announced", "Connection: close\r\n", "connection closed after response 2 of 3"},
+		{"abrupt", "", "could not read response 3"},
+	} {
+		s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+			io.WriteString(w, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n")
+			if req.URL.Path == "/2" {
+				io.WriteString(w, tc.header)
+			}
+			io.WriteString(w, "\r\nok")
+			return req.URL.Path == "/2"
+		})
+		responses, err := NewClient(testOptions()).Pipeline(s.URL, pipelined("GET", "/1", "/2", "/3"))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+		}
+		if len(responses) != 2 {
+			t.Fatalf("%s: got %d responses, want the 2 read before the close", tc.name, len(responses))
+		}
+		for _, resp := range responses {
+			if b, _ := io.ReadAll(resp.Body); string(b) != "ok" {
+				t.Errorf("%s: got body %q", tc.name, b)
+			}
+		}
+	}
+}
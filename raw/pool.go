@@ -0,0 +1,128 @@
+package raw
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/12end/request/raw/client"
+)
+
+const (
+	defaultMaxIdleConnsPerHost = 4
+	defaultIdleConnTimeout     = 30 * time.Second
+	// maxDrain is the most unread body bytes discarded to keep a connection alive
+	maxDrain     = 4 << 10
+	drainTimeout = 100 * time.Millisecond
+)
+
+// PoolStats reports the keep-alive pool usage of a Client
+type PoolStats struct {
+	Dials     uint64 // connections dialed
+	Reuses    uint64 // requests sent over a pooled connection
+	Discarded uint64 // pooled connections closed as expired, dead, unread or over the limit
+	Idle      int    // connections currently idle in the pool
+}
+
+// PoolStats returns the keep-alive pool statistics of the client
+func (c *Client) PoolStats() PoolStats {
+	if d, ok := c.dialer.(*dialer); ok {
+		return d.stats()
+	}
+	return PoolStats{}
+}
+
+// CloseIdleConnections closes all connections kept alive by the client
+func (c *Client) CloseIdleConnections() {
+	if d, ok := c.dialer.(*dialer); ok {
+		d.closeIdle()
+	}
+}
+
+// isAlive reports whether an idle connection can be reused, that is the peer
+// neither closed it nor sent unsolicited data.
+func isAlive(c net.Conn) bool {
+	if tc, ok := c.(*tls.Conn); ok {
+		// don't mess with the tls record layer, a close_notify alert
+		// shows up as pending data on the underlying connection anyway
+		c = tc.NetConn()
+	}
+	_ = c.SetReadDeadline(time.Now().Add(time.Millisecond))
+	var b [1]byte
+	_, err := c.Read(b[:])
+	_ = c.SetReadDeadline(time.Time{})
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// keepAlive reports whether the connection can be reused after resp has been read
+func keepAlive(req *client.Request, resp *client.Response, options *Options) bool {
+	if options.DisableKeepAlives || options.ForceReadAllBody || len(req.RawBytes) > 0 {
+		return false
+	}
+	if resp.CloseRequested() {
+		return false
+	}
+	if resp.Version.Major < 1 || (resp.Version.Major == 1 && resp.Version.Minor == 0) {
+		if !strings.EqualFold(headerValue(fromHeaders(resp.Headers), "Connection"), "keep-alive") {
+			return false
+		}
+	}
+	if resp.Status.Code == client.INFO_SWITCHING_PROTOCOL {
+		return false
+	}
+	if !hasBody(req.Method, resp.Status.Code) {
+		return true
+	}
+	// the end of the body must be known without the server closing the connection
+	return resp.ContentLength() >= 0 || resp.TransferEncoding() == "chunked"
+}
+
+// eofReader records whether the wrapped reader has been read to the end
+type eofReader struct {
+	io.Reader
+	eof bool
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// bodyCloser releases the connection back to the pool when the response body
+// is closed after being read completely, otherwise the connection is closed.
+type bodyCloser struct {
+	conn      Conn
+	body      *eofReader
+	keepAlive bool
+	stop      func()
+	closed    bool
+
+	trace *exchangeTrace // nil unless traced
+	resp  *client.Response
+}
+
+func (b *bodyCloser) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.stop()
+	if b.keepAlive && !b.body.eof {
+		// decoders often stop right before the end of the raw body
+		_ = b.conn.SetReadDeadline(time.Now().Add(drainTimeout))
+		_, _ = io.CopyN(io.Discard, b.body, maxDrain)
+	}
+	// before the connection can be picked up by another request
+	b.trace.record(b.resp)
+	if b.keepAlive && b.body.eof {
+		b.conn.Release()
+		return nil
+	}
+	return b.conn.Close()
+}
@@ -0,0 +1,193 @@
+package raw
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rawServer answers every request read from a connection with what respond
+// writes verbatim, and closes the connection if it returns true.
+type rawServer struct {
+	ln    net.Listener
+	URL   string
+	conns int32 // connections accepted
+}
+
+func newRawServer(t *testing.T, respond func(req *http.Request, w io.Writer) (close bool)) *rawServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &rawServer{ln: ln, URL: "http://" + ln.Addr().String()}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.conns, 1)
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					req, err := http.ReadRequest(br)
+					if err != nil {
+						return
+					}
+					_, _ = io.Copy(io.Discard, req.Body)
+					if respond(req, conn) {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *rawServer) accepted() int {
+	return int(atomic.LoadInt32(&s.conns))
+}
+
+func testOptions() *Options {
+	return &Options{
+		Timeout:                2 * time.Second,
+		AutomaticHostHeader:    true,
+		AutomaticContentLength: true,
+	}
+}
+
+func TestPoolReusesConnection(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		io.WriteString(w, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		return false
+	})
+	c := NewClient(testOptions())
+	for i := 0; i < 3; i++ {
+		resp, err := c.DoRaw("GET", s.URL, "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "ok" {
+			t.Fatalf("got body %q", b)
+		}
+	}
+	if n := s.accepted(); n != 1 {
+		t.Errorf("%d connections dialed, want 1", n)
+	}
+	if stats := c.PoolStats(); stats.Reuses != 2 || stats.Idle != 1 {
+		t.Errorf("got %+v", stats)
+	}
+	c.CloseIdleConnections()
+	if stats := c.PoolStats(); stats.Idle != 0 {
+		t.Errorf("%d idle connections after CloseIdleConnections", stats.Idle)
+	}
+}
+
+func TestPoolDrainsUnreadBody(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		io.WriteString(w, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+		return false
+	})
+	c := NewClient(testOptions())
+	for i := 0; i < 2; i++ {
+		resp, err := c.DoRaw("GET", s.URL, "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// closed without reading, the body is small enough to be drained
+		resp.Body.Close()
+	}
+	if n := s.accepted(); n != 1 {
+		t.Errorf("%d connections dialed, want 1", n)
+	}
+}
+
+func TestPoolDiscardsClosedConnection(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		io.WriteString(w, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		// the server goes away without saying so
+		return true
+	})
+	c := NewClient(testOptions())
+	for i := 0; i < 2; i++ {
+		resp, err := c.DoRaw("GET", s.URL, "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := s.accepted(); n != 2 {
+		t.Errorf("%d connections dialed, want 2", n)
+	}
+	if stats := c.PoolStats(); stats.Discarded != 1 {
+		t.Errorf("got %+v, want the dead connection discarded", stats)
+	}
+}
+
+func TestIsAlive(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	if !isAlive(client) {
+		t.Error("idle connection reported dead")
+	}
+	go server.Write([]byte("x"))
+	time.Sleep(10 * time.Millisecond)
+	if isAlive(client) {
+		t.Error("connection with unsolicited data reported alive")
+	}
+	server.Close()
+	if isAlive(client) {
+		t.Error("closed connection reported alive")
+	}
+}
+
+func TestPoolResponsesWithoutBody(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		switch req.URL.Path {
+		case "/no-content":
+			io.WriteString(w, "HTTP/1.1 204 No Content\r\nContent-Length: 5\r\n\r\n")
+		case "/not-modified":
+			io.WriteString(w, "HTTP/1.1 304 Not Modified\r\nContent-Length: 100\r\n\r\n")
+		default:
+			// the length of the body a GET would get
+			io.WriteString(w, "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\n")
+		}
+		return false
+	})
+	c := NewClient(testOptions())
+	for _, tc := range []struct{ method, path string }{
+		{"HEAD", "/"},
+		{"GET", "/no-content"},
+		{"GET", "/not-modified"},
+		{"HEAD", "/"},
+	} {
+		start := time.Now()
+		resp, err := c.DoRaw(tc.method, s.URL+tc.path, "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || len(b) != 0 {
+			t.Errorf("%s %s: got body %q, %v", tc.method, tc.path, b, err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s %s: took %v", tc.method, tc.path, d)
+		}
+	}
+	if n := s.accepted(); n != 1 {
+		t.Errorf("%d connections dialed, want 1", n)
+	}
+}
@@ -0,0 +1,133 @@
+package raw
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	stdurl "net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// parseProxy parses a proxy url, plain host:port values are treated as http proxies
+func parseProxy(rawProxy string) (*stdurl.URL, error) {
+	if !strings.Contains(rawProxy, "://") {
+		rawProxy = "http://" + rawProxy
+	}
+	u, err := stdurl.Parse(rawProxy)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse proxy url: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+	}
+	if u.Port() == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			u.Host = net.JoinHostPort(u.Hostname(), "80")
+		case "https":
+			u.Host = net.JoinHostPort(u.Hostname(), "443")
+		default:
+			u.Host = net.JoinHostPort(u.Hostname(), "1080")
+		}
+	}
+	return u, nil
+}
+
+// proxyDial opens a tunnel to addr through the given proxy. The returned
+// connection is a plain byte stream to the target, so whatever is written
+// to it reaches the target unchanged.
+func proxyDial(ctx context.Context, rawProxy, addr string, timeout time.Duration) (net.Conn, error) {
+	u, err := parseProxy(rawProxy)
+	if err != nil {
+		return nil, err
+	}
+	forward := &net.Dialer{Timeout: timeout}
+
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+		if err != nil {
+			return nil, err
+		}
+		return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	}
+
+	conn, err := forward.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+	stop := watchContext(ctx, conn)
+	defer stop()
+	if strings.EqualFold(u.Scheme, "https") {
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, contextErr(ctx, err)
+		}
+		conn = tlsConn
+	}
+	conn, err = httpConnect(conn, u, addr)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpConnect asks an http proxy to open a tunnel to addr with the CONNECT method
+func httpConnect(conn net.Conn, u *stdurl.URL, addr string) (net.Conn, error) {
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req += "Proxy-Authorization: Basic " + auth + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not read proxy response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to proxy: %s status code: %d", u.Host, resp.StatusCode)
+	}
+	// the proxy may have already relayed bytes from the target
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose reads are served from r first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
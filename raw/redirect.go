@@ -0,0 +1,82 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	stdurl "net/url"
+	"strings"
+
+	"github.com/12end/request"
+	"github.com/12end/request/raw/client"
+)
+
+// ErrUseLastResponse can be returned by Options.CheckRedirect to stop
+// following redirects and get the last response, with its body unread.
+var ErrUseLastResponse = errors.New("use last response")
+
+// Redirect is a request of a redirect chain
+type Redirect struct {
+	Method  string
+	URL     string
+	Headers map[string][]string
+	// Response is the redirect response leading to this request, nil for
+	// the first one. Its body is unread while CheckRedirect runs.
+	Response *http.Response
+}
+
+// bodyHeaders describe the body of a request, they are dropped along with
+// the body when a redirect switches to GET
+var bodyHeaders = map[string]bool{
+	"content-length":    true,
+	"content-type":      true,
+	"transfer-encoding": true,
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case client.REDIRECTION_MOVED_PERMANENTLY, client.REDIRECTION_MOVED_TEMPORARILY, client.REDIRECTION_SEE_OTHER,
+		client.REDIRECTION_TEMPORARY_REDIRECT, client.REDIRECTION_PERMANENT_REDIRECT:
+		return true
+	}
+	return false
+}
+
+// nextRedirect returns the request following the redirect response r to a
+// method request for u, or nil if r has no Location. The Location is
+// resolved against u as described in RFC 3986.
+func nextRedirect(r *http.Response, resp *client.Response, method string, u *stdurl.URL, headers map[string][]string, options *Options) (*Redirect, error) {
+	location := responseHeader(resp, "Location")
+	if location == "" {
+		return nil, nil
+	}
+	ref, err := stdurl.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Location %q: %w", location, err)
+	}
+	next := &Redirect{
+		Method:   method,
+		URL:      u.ResolveReference(ref).String(),
+		Headers:  headers,
+		Response: r,
+	}
+	if !options.PreserveMethodOnRedirect {
+		next.Method = request.RedirectMethod(r.StatusCode, method)
+	}
+	if next.Method != method {
+		next.Headers = make(map[string][]string, len(headers))
+		for k, v := range headers {
+			if !bodyHeaders[strings.ToLower(k)] {
+				next.Headers[k] = v
+			}
+		}
+	}
+	return next, nil
+}
+
+// rewindBody prepares body, sent with a method request, to be sent again
+// to next. It reports false if the body can't be sent twice.
+func rewindBody(next *Redirect, method string, body io.Reader) bool {
+	return next.Method != method || rewind(body)
+}
@@ -0,0 +1,165 @@
+package raw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedirectDropsChunkedBody(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		switch req.URL.Path {
+		case "/see-other":
+			io.WriteString(w, "HTTP/1.1 303 See Other\r\nLocation: /next\r\nContent-Length: 0\r\n\r\n")
+		case "/temporary":
+			io.WriteString(w, "HTTP/1.1 307 Temporary Redirect\r\nLocation: /next\r\nContent-Length: 0\r\n\r\n")
+		default:
+			body := fmt.Sprintf("%s %q %d", req.Method, req.TransferEncoding, req.ContentLength)
+			fmt.Fprintf(w, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+		}
+		return false
+	})
+	options := testOptions()
+	options.FollowRedirects = true
+	options.MaxRedirects = 3
+	options.Chunked = true
+	c := NewClient(options)
+
+	for path, want := range map[string]string{
+		"/see-other": `GET [] 0`,
+		"/temporary": `POST ["chunked"] -1`,
+	} {
+		resp, err := c.DoRaw("POST", s.URL+path, "", nil, strings.NewReader("a=1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != want {
+			t.Errorf("%s: next hop got %s, want %s", path, b, want)
+		}
+	}
+	if !options.Chunked {
+		t.Error("the options of the client were changed")
+	}
+}
+
+func TestRedirectResolvesLocation(t *testing.T) {
+	var s *rawServer
+	s = newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		location := map[string]string{
+			"/a/b/relative": "../x",
+			"/a/b/network":  "//" + strings.TrimPrefix(s.URL, "http://") + "/y",
+			"/a/b/absolute": "/z?q=1",
+			"/a/b/query":    "?q=2",
+		}[req.RequestURI]
+		if location != "" {
+			fmt.Fprintf(w, "HTTP/1.1 302 Found\r\nLocation: %s\r\nContent-Length: 0\r\n\r\n", location)
+		} else {
+			fmt.Fprintf(w, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(req.RequestURI), req.RequestURI)
+		}
+		return false
+	})
+	options := testOptions()
+	options.FollowRedirects = true
+	options.MaxRedirects = 3
+	c := NewClient(options)
+
+	for path, want := range map[string]string{
+		"/a/b/relative": "/a/x",
+		"/a/b/network":  "/y",
+		"/a/b/absolute": "/z?q=1",
+		"/a/b/query":    "/a/b/query?q=2",
+	} {
+		resp, err := c.DoRaw("GET", s.URL+path, "", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != want {
+			t.Errorf("%s: redirected to %s, want %s", path, b, want)
+		}
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	s := newRawServer(t, func(req *http.Request, w io.Writer) bool {
+		switch req.URL.Path {
+		case "/1":
+			io.WriteString(w, "HTTP/1.1 302 Found\r\nLocation: /2\r\nContent-Length: 0\r\n\r\n")
+		case "/2":
+			io.WriteString(w, "HTTP/1.1 302 Found\r\nLocation: /3\r\nContent-Length: 0\r\n\r\n")
+		default:
+			body := req.URL.Path + " " + req.Header.Get("X-Hop")
+			fmt.Fprintf(w, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+		}
+		return false
+	})
+	options := testOptions()
+	options.FollowRedirects = true
+	options.MaxRedirects = 5
+	errStop := fmt.Errorf("stop")
+
+	for _, tc := range []struct {
+		name   string
+		check  func(next *Redirect, via []*Redirect) error
+		status int
+		body   string
+		err    error
+	}{
+		{
+			name: "rewrite",
+			check: func(next *Redirect, via []*Redirect) error {
+				if next.Response == nil || next.Response.StatusCode != 302 {
+					return fmt.Errorf("next.Response is %v", next.Response)
+				}
+				if len(via) == 2 {
+					next.URL = strings.Replace(next.URL, "/3", "/rewritten", 1)
+				}
+				next.Headers = map[string][]string{"X-Hop": {fmt.Sprint(len(via))}}
+				return nil
+			},
+			status: 200,
+			body:   "/rewritten 2",
+		},
+		{
+			name: "use last response",
+			check: func(next *Redirect, via []*Redirect) error {
+				if len(via) == 2 {
+					if !strings.HasSuffix(via[0].URL, "/1") || !strings.HasSuffix(via[1].URL, "/2") {
+						return fmt.Errorf("got via %s, %s", via[0].URL, via[1].URL)
+					}
+					return ErrUseLastResponse
+				}
+				return nil
+			},
+			status: 302,
+		},
+		{
+			name:  "error",
+			check: func(next *Redirect, via []*Redirect) error { return errStop },
+			err:   errStop,
+		},
+	} {
+		options.CheckRedirect = tc.check
+		resp, err := NewClient(options).DoRaw("GET", s.URL+"/1", "", nil, nil)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got error %v, want %v", tc.name, err, tc.err)
+		}
+		if err != nil {
+			continue
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || string(b) != tc.body {
+			t.Errorf("%s: got %d %q", tc.name, resp.StatusCode, b)
+		}
+		if tc.status == 302 && resp.Header.Get("Location") != "/3" {
+			t.Errorf("%s: got the redirect to %q", tc.name, resp.Header.Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package raw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/12end/request"
+	"github.com/12end/request/raw/client"
+	"github.com/valyala/fasthttp"
+)
+
+// Response is a raw response with the helpers of request.Response, so that
+// matchers can be written once against both clients. The embedded
+// request.Response is built from the decoded body, while StatusLine and
+// Headers keep what was received on the wire.
+type Response struct {
+	*request.Response
+	// StatusLine is the status line as received, without the line break
+	StatusLine string
+	// Headers are the response headers in received order, duplicates included
+	Headers client.Headers
+	// Anomalies are the deviations from the RFCs tolerated while parsing the
+	// response with Options.LenientParsing
+	Anomalies []string
+
+	raw *client.Response
+}
+
+// wireHeaders describe the encoding of the body on the wire, they don't
+// apply to the decoded body of the embedded request.Response
+var wireHeaders = map[string]bool{
+	"content-encoding":  true,
+	"content-length":    true,
+	"transfer-encoding": true,
+}
+
+// NewResponse reads and closes the body of r, which should come from a raw
+// Client. Responses of other clients are supported as well, but their
+// status line and header order are reconstructed.
+func NewResponse(r *http.Response) (*Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err := firstErr(err, r.Body.Close()); err != nil {
+		return nil, err
+	}
+
+	resp := &Response{}
+	if rc, ok := r.Body.(*readCloser); ok && rc.resp != nil {
+		resp.StatusLine = rc.resp.StatusLine
+		resp.Headers = rc.resp.Headers
+		resp.Anomalies = rc.resp.Anomalies
+		resp.raw = rc.resp
+	} else {
+		resp.StatusLine = fmt.Sprintf("HTTP/%d.%d %s", r.ProtoMajor, r.ProtoMinor, r.Status)
+		for k, values := range r.Header {
+			for _, v := range values {
+				resp.Headers = append(resp.Headers, client.Header{Key: k, Value: v})
+			}
+		}
+	}
+
+	fr := &fasthttp.Response{}
+	fr.Header.SetStatusCode(r.StatusCode)
+	if i := strings.Index(r.Status, " "); i >= 0 {
+		fr.Header.SetStatusMessage([]byte(r.Status[i+1:]))
+	}
+	for _, h := range resp.Headers {
+		if !wireHeaders[strings.ToLower(h.Key)] {
+			fr.Header.Add(h.Key, h.Value)
+		}
+	}
+	if !r.Uncompressed {
+		// the body is still encoded, let the helpers of request.Response decode it
+		for _, h := range resp.Headers {
+			if strings.EqualFold(h.Key, "Content-Encoding") {
+				fr.Header.Add(h.Key, h.Value)
+			}
+		}
+	}
+	fr.SetBody(body)
+	resp.Response = &request.Response{Response: fr}
+	return resp, nil
+}
+
+// GetHeader returns the first value of the header k, matched case-insensitively
+func (r *Response) GetHeader(k string) (string, bool) {
+	for _, h := range r.Headers {
+		if strings.EqualFold(h.Key, k) {
+			return h.Value, true
+		}
+	}
+	return "", false
+}
+
+// HeaderContains reports whether the status line or header lines as received contain s
+func (r *Response) HeaderContains(s string) bool {
+	return strings.Contains(r.head(), s)
+}
+
+// RawBytes returns the head and body of the response as received, if the
+// request was sent with Options.CaptureRawBytes.
+func (r *Response) RawBytes() (head, body []byte) {
+	if r.raw == nil {
+		return nil, nil
+	}
+	return r.raw.RawBytes()
+}
+
+// String returns the response with its status line and headers as received
+// and the decoded body.
+func (r *Response) String() string {
+	return r.head() + "\r\n" + string(r.Response.Body())
+}
+
+func (r *Response) head() string {
+	return formatHead(r.StatusLine, r.Headers)
+}
+
+// formatHead returns the status line and header lines, keeping header lines
+// without value as they are
+func formatHead(statusLine string, headers client.Headers) string {
+	var b bytes.Buffer
+	b.WriteString(statusLine + "\r\n")
+	for _, h := range headers {
+		if h.Value != "" {
+			b.WriteString(h.Key + ": " + h.Value + "\r\n")
+		} else {
+			b.WriteString(h.Key + "\r\n")
+		}
+	}
+	return b.String()
+}
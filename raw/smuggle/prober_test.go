@@ -0,0 +1,375 @@
+package smuggle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/12end/request/raw"
+)
+
+// framing is how a simulated server finds the end of a request body
+type framing int
+
+const (
+	firstCL framing = iota // the first Content-Length, Transfer-Encoding is ignored
+	lastCL                 // the last Content-Length, Transfer-Encoding is ignored
+	chunked                // "Transfer-Encoding: chunked" verbatim, else the first Content-Length
+	strict                 // any chunked Transfer-Encoding wins, conflicting lengths are rejected
+)
+
+// backendTimeout is how long the front-end waits for the back-end before it
+// drops the back-end connection, longer than the client timeout.
+const backendTimeout = 1500 * time.Millisecond
+
+// stack simulates a front-end forwarding the requests of all its clients
+// over a single back-end connection, each side framing requests its own way.
+// The back-end answers 200 for "/" and 404 for any other path.
+type stack struct {
+	URL   string
+	front framing
+
+	mu      sync.Mutex
+	backend net.Conn
+	br      *bufio.Reader
+	dial    func() (net.Conn, error)
+}
+
+func newStack(t *testing.T, front, back framing) *stack {
+	t.Helper()
+	backLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frontLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		frontLn.Close()
+		backLn.Close()
+	})
+	s := &stack{
+		URL:   "http://" + frontLn.Addr().String() + "/",
+		front: front,
+		dial:  func() (net.Conn, error) { return net.Dial("tcp", backLn.Addr().String()) },
+	}
+	serve(backLn, func(conn net.Conn) { serveBackend(conn, back) })
+	serve(frontLn, s.serveFrontend)
+	return s
+}
+
+func serve(ln net.Listener, handle func(net.Conn)) {
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+}
+
+func serveBackend(conn net.Conn, f framing) {
+	br := bufio.NewReader(conn)
+	for {
+		_, path, err := readRequest(br, f)
+		if err != nil {
+			return
+		}
+		if path == "/" {
+			io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		} else {
+			io.WriteString(conn, "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+		}
+	}
+}
+
+func (s *stack) serveFrontend(conn net.Conn) {
+	br := bufio.NewReader(conn)
+	for {
+		req, _, err := readRequest(br, s.front)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n")
+			}
+			return
+		}
+		status, err := s.forward(req)
+		if err != nil {
+			io.WriteString(conn, "HTTP/1.1 504 Gateway Timeout\r\nContent-Length: 0\r\n\r\n")
+			return
+		}
+		fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\n\r\n", status, http.StatusText(status))
+	}
+}
+
+// forward writes req to the back-end connection and returns the status of
+// the next response read from it.
+func (s *stack) forward(req []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backend == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return 0, err
+		}
+		s.backend, s.br = conn, bufio.NewReader(conn)
+	}
+	_ = s.backend.SetDeadline(time.Now().Add(backendTimeout))
+	if _, err := s.backend.Write(req); err != nil {
+		s.reset()
+		return 0, err
+	}
+	resp, err := http.ReadResponse(s.br, nil)
+	if err != nil {
+		s.reset()
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (s *stack) reset() {
+	s.backend.Close()
+	s.backend, s.br = nil, nil
+}
+
+// readRequest reads a request framed by f and returns its bytes and path
+func readRequest(br *bufio.Reader, f framing) ([]byte, string, error) {
+	var raw bytes.Buffer
+	var (
+		path    string
+		lengths []int
+		te      []string
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, "", err
+		}
+		raw.WriteString(line)
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if path == "" {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				return nil, "", fmt.Errorf("malformed request line %q", line)
+			}
+			path = fields[1]
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "Content-Length":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, "", err
+			}
+			lengths = append(lengths, n)
+		case strings.EqualFold(strings.TrimSpace(name), "Transfer-Encoding"):
+			te = append(te, line)
+		}
+	}
+
+	useChunked := false
+	length := 0
+	if len(lengths) > 0 {
+		length = lengths[0]
+	}
+	switch f {
+	case lastCL:
+		if len(lengths) > 0 {
+			length = lengths[len(lengths)-1]
+		}
+	case chunked:
+		useChunked = len(te) == 1 && te[0] == "Transfer-Encoding: chunked"
+	case strict:
+		for _, line := range te {
+			useChunked = useChunked || strings.Contains(strings.ToLower(line), "chunked")
+		}
+		for _, n := range lengths {
+			if n != length && !useChunked {
+				return nil, "", errors.New("conflicting Content-Length")
+			}
+		}
+	}
+	if useChunked {
+		if err := readChunked(br, &raw); err != nil {
+			return nil, "", err
+		}
+	} else if _, err := io.CopyN(&raw, br, int64(length)); err != nil {
+		return nil, "", err
+	}
+	return raw.Bytes(), path, nil
+}
+
+// readChunked copies a chunked body to raw, rejecting a malformed chunk size
+// as soon as its first invalid byte is read like nginx does.
+func readChunked(br *bufio.Reader, raw *bytes.Buffer) error {
+	for {
+		size := 0
+		digits := 0
+		for {
+			c, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			raw.WriteByte(c)
+			if n := strings.IndexByte("0123456789abcdef", lower(c)); n >= 0 {
+				size = size*16 + n
+				digits++
+				continue
+			}
+			if (c != '\r' && c != ';') || digits == 0 {
+				return fmt.Errorf("invalid chunk size byte %q", c)
+			}
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			raw.WriteString(line)
+			break
+		}
+		if size == 0 {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			raw.WriteString(line)
+			return nil
+		}
+		if _, err := io.CopyN(raw, br, int64(size)+2); err != nil {
+			return err
+		}
+	}
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func testProber() *Prober {
+	p := NewProber(raw.NewClient(&raw.Options{}))
+	p.Timeout = time.Second
+	p.Threshold = 500 * time.Millisecond
+	p.Confirmations = 1
+	return p
+}
+
+func plain(d Desync) Variant {
+	if d == CLTE || d == TECL {
+		return Variant{Name: "plain", Desync: d, TransferEncoding: transferEncodings[0].lines}
+	}
+	return Variant{Name: "duplicate-content-length", Desync: d}
+}
+
+func TestProbeDetectsDesync(t *testing.T) {
+	for _, tc := range []struct {
+		desync      Desync
+		front, back framing
+	}{
+		{CLTE, firstCL, chunked},
+		{TECL, chunked, firstCL},
+		{CLCL, firstCL, lastCL},
+		{CLCLReversed, lastCL, firstCL},
+	} {
+		tc := tc
+		t.Run(tc.desync.String(), func(t *testing.T) {
+			t.Parallel()
+			s := newStack(t, tc.front, tc.back)
+			base, err := raw.Parse("GET / HTTP/1.1\r\nHost: x\r\n\r\n", s.URL, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			base.FullURL = s.URL
+
+			results, err := testProber().Probe(context.Background(), base, []Variant{plain(tc.desync)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 2 {
+				t.Fatalf("got %d results, want a timing and a differential one", len(results))
+			}
+			for _, r := range results {
+				if !r.Vulnerable {
+					t.Errorf("%s: not vulnerable: %+v", r.Technique, r)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeQuietOnCompliantServer(t *testing.T) {
+	s := newStack(t, strict, strict)
+	base, err := raw.Parse("GET / HTTP/1.1\r\nHost: x\r\n\r\n", s.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.FullURL = s.URL
+
+	p := testProber()
+	for _, d := range []Desync{CLTE, TECL, CLCL, CLCLReversed} {
+		// the timing probe alone, Probe skips TE.CL after a CL.TE hit
+		r, err := p.Timing(context.Background(), base, plain(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Vulnerable {
+			t.Errorf("%s: reported vulnerable: %+v", d, r)
+		}
+	}
+}
+
+func TestProbeContinuesAfterInconclusiveDifferential(t *testing.T) {
+	s := newStack(t, firstCL, chunked)
+	base, err := raw.Parse("GET / HTTP/1.1\r\nHost: x\r\n\r\n", s.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.FullURL = s.URL
+
+	p := testProber()
+	// the smuggled path can't be told apart from the base path
+	p.SmuggledPath = "/"
+	variants := []Variant{plain(CLTE), plain(CLTE)}
+	variants[1].Name = "plain-again"
+	results, err := p.Probe(context.Background(), base, variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for i, r := range results {
+		if r.Variant.Name != variants[i/2].Name {
+			t.Errorf("result %d is for %s", i, r.Variant)
+		}
+		if r.Technique == Differential && (!r.Inconclusive || r.Err == nil) {
+			t.Errorf("result %d: got %+v, want inconclusive", i, r)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package smuggle generates HTTP request smuggling (desync) probes from a
+// raw.Request and detects which of them a target is vulnerable to.
+package smuggle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Desync is the disagreement between front-end and back-end a variant targets
+type Desync int
+
+const (
+	// CLTE front-end uses Content-Length, back-end uses Transfer-Encoding
+	CLTE Desync = iota
+	// TECL front-end uses Transfer-Encoding, back-end uses Content-Length
+	TECL
+	// CLCL front-end uses the first Content-Length, back-end the last one
+	CLCL
+	// CLCLReversed front-end uses the last Content-Length, back-end the first one
+	CLCLReversed
+)
+
+func (d Desync) String() string {
+	switch d {
+	case CLTE:
+		return "CL.TE"
+	case TECL:
+		return "TE.CL"
+	case CLCL:
+		return "CL.CL"
+	case CLCLReversed:
+		return "CL.CL-reversed"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Variant is a single way of framing the probe so that one side of the
+// connection misreads the body length.
+type Variant struct {
+	Name   string
+	Desync Desync
+	// TransferEncoding are the header lines announcing the chunked body,
+	// they are joined with NewLine.
+	TransferEncoding []string
+	// ChunkSize formats a chunk size, lowercase hex if nil
+	ChunkSize func(n int) string
+	// NewLine terminates the header lines, CRLF if empty
+	NewLine string
+	// ChunkNewLine terminates the chunk framing lines, CRLF if empty
+	ChunkNewLine string
+}
+
+func (v Variant) String() string {
+	return v.Desync.String() + "/" + v.Name
+}
+
+func (v Variant) chunkSize(n int) string {
+	if v.ChunkSize != nil {
+		return v.ChunkSize(n)
+	}
+	return fmt.Sprintf("%x", n)
+}
+
+func (v Variant) newLine() string {
+	if v.NewLine != "" {
+		return v.NewLine
+	}
+	return "\r\n"
+}
+
+func (v Variant) chunkNewLine() string {
+	if v.ChunkNewLine != "" {
+		return v.ChunkNewLine
+	}
+	return "\r\n"
+}
+
+// transferEncodings are the Transfer-Encoding obfuscations, each hides the
+// header from some parsers but not from others.
+var transferEncodings = []struct {
+	name  string
+	lines []string
+}{
+	{"plain", []string{"Transfer-Encoding: chunked"}},
+	{"space-before-colon", []string{"Transfer-Encoding : chunked"}},
+	{"no-space", []string{"Transfer-Encoding:chunked"}},
+	{"tab", []string{"Transfer-Encoding:\tchunked"}},
+	{"vertical-tab", []string{"Transfer-Encoding:\vchunked"}},
+	{"uppercase", []string{"Transfer-Encoding: CHUNKED"}},
+	{"lowercase-name", []string{"transfer-encoding: chunked"}},
+	{"quoted", []string{`Transfer-Encoding: "chunked"`}},
+	{"xchunked", []string{"Transfer-Encoding: xchunked"}},
+	{"identity-chunked", []string{"Transfer-Encoding: identity, chunked"}},
+	{"chunked-identity", []string{"Transfer-Encoding: chunked, identity"}},
+	{"dual-garbage", []string{"Transfer-Encoding: chunked", "Transfer-Encoding: x"}},
+	{"dual-identity", []string{"Transfer-Encoding: identity", "Transfer-Encoding: chunked"}},
+	{"line-fold", []string{"Transfer-Encoding:", " chunked"}},
+	{"leading-space", []string{"X-Foo: bar", " Transfer-Encoding: chunked"}},
+	{"cr-suffix", []string{"Transfer-Encoding: chunked\r"}},
+	{"null-suffix", []string{"Transfer-Encoding: chunked\x00"}},
+	{"lf-in-name", []string{"Transfer-Encoding\n: chunked"}},
+}
+
+// chunkSizes are the chunk size encodings which not all parsers agree on
+var chunkSizes = []struct {
+	name string
+	f    func(n int) string
+}{
+	{"chunk-leading-zeros", func(n int) string { return fmt.Sprintf("%08x", n) }},
+	{"chunk-uppercase", func(n int) string { return strings.ToUpper(fmt.Sprintf("%x", n)) }},
+	{"chunk-extension", func(n int) string { return fmt.Sprintf("%x;ext=1", n) }},
+	{"chunk-space-suffix", func(n int) string { return fmt.Sprintf("%x ", n) }},
+	{"chunk-0x-prefix", func(n int) string { return fmt.Sprintf("0x%x", n) }},
+}
+
+// Variants returns the standard desync variants, all CL.TE ones first as
+// TE.CL probes can poison the back-end connection of CL.TE targets.
+func Variants() []Variant {
+	var variants []Variant
+	for _, desync := range []Desync{CLTE, TECL} {
+		for _, te := range transferEncodings {
+			variants = append(variants, Variant{Name: te.name, Desync: desync, TransferEncoding: te.lines})
+		}
+		for _, cs := range chunkSizes {
+			variants = append(variants, Variant{
+				Name:             cs.name,
+				Desync:           desync,
+				TransferEncoding: transferEncodings[0].lines,
+				ChunkSize:        cs.f,
+			})
+		}
+		variants = append(variants,
+			Variant{Name: "lf-headers", Desync: desync, TransferEncoding: transferEncodings[0].lines, NewLine: "\n"},
+			Variant{Name: "lf-chunks", Desync: desync, TransferEncoding: transferEncodings[0].lines, ChunkNewLine: "\n"},
+		)
+	}
+	variants = append(variants,
+		Variant{Name: "duplicate-content-length", Desync: CLCL},
+		Variant{Name: "duplicate-content-length", Desync: CLCLReversed},
+	)
+	return variants
+}
@@ -0,0 +1,101 @@
+package raw
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/12end/request"
+	"github.com/12end/request/raw/client"
+)
+
+// dialTimings are the durations of the steps of dialing a connection
+type dialTimings struct {
+	dns, connect, tlsHandshake time.Duration
+}
+
+// connTracer records when bytes of the current exchange are read, and what
+// is written if recording.
+type connTracer struct {
+	net.Conn
+	firstByte, lastByte time.Time
+	written             *bytes.Buffer
+}
+
+func (t *connTracer) reset(record bool) {
+	t.firstByte, t.lastByte = time.Time{}, time.Time{}
+	t.written = nil
+	if record {
+		t.written = new(bytes.Buffer)
+	}
+}
+
+func (t *connTracer) Read(b []byte) (int, error) {
+	n, err := t.Conn.Read(b)
+	if n > 0 {
+		now := time.Now()
+		if t.firstByte.IsZero() {
+			t.firstByte = now
+		}
+		t.lastByte = now
+	}
+	return n, err
+}
+
+func (t *connTracer) Write(b []byte) (int, error) {
+	n, err := t.Conn.Write(b)
+	if t.written != nil {
+		t.written.Write(b[:n])
+	}
+	return n, err
+}
+
+// exchangeTrace collects the TraceInfo of a single exchange
+type exchangeTrace struct {
+	start, sent time.Time
+	conn        *conn
+	traces      *[]request.TraceInfo
+	attempt     int
+}
+
+// startTrace starts tracing the attempt-th attempt of an exchange about to
+// be sent over c, which was requested at start.
+func startTrace(c Conn, start time.Time, traces *[]request.TraceInfo, attempt int) *exchangeTrace {
+	t := &exchangeTrace{start: start, traces: traces, attempt: attempt}
+	if cn, ok := c.(*conn); ok {
+		t.conn = cn
+		cn.tracer.reset(true)
+	}
+	t.sent = time.Now()
+	return t
+}
+
+// record appends the TraceInfo of the exchange, t may be nil and resp is
+// nil if no response was read.
+func (t *exchangeTrace) record(resp *client.Response) {
+	if t == nil {
+		return
+	}
+	info := request.TraceInfo{Duration: time.Since(t.start), Attempt: t.attempt}
+	if resp != nil {
+		info.Response = formatHead(resp.StatusLine, resp.Headers)
+	}
+	if c := t.conn; c != nil {
+		info.Request = c.tracer.written.String()
+		info.Reused = c.reused
+		if !c.reused {
+			info.DNSLookup = c.timings.dns
+			info.Connect = c.timings.connect
+			info.TLSHandshake = c.timings.tlsHandshake
+		}
+		if !c.tracer.firstByte.IsZero() {
+			info.TTFB = c.tracer.firstByte.Sub(t.sent)
+			info.BodyRead = c.tracer.lastByte.Sub(c.tracer.firstByte)
+		}
+		if host, _, err := net.SplitHostPort(c.Conn.RemoteAddr().String()); err == nil {
+			info.RemoteIP = host
+		}
+		c.tracer.reset(false)
+	}
+	*t.traces = append(*t.traces, info)
+}
@@ -0,0 +1,52 @@
+package raw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/12end/request"
+)
+
+func TestTrace(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	for scheme, s := range map[string]*httptest.Server{
+		"http":  httptest.NewServer(handler),
+		"https": httptest.NewTLSServer(handler),
+	} {
+		defer s.Close()
+		var traces []request.TraceInfo
+		options := testOptions()
+		options.Trace = &traces
+		c := NewClient(options)
+		for i := 0; i < 2; i++ {
+			resp, err := c.DoRaw("GET", s.URL, "", nil, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", scheme, err)
+			}
+			_, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		if len(traces) != 2 {
+			t.Fatalf("%s: got %d traces, want 2", scheme, len(traces))
+		}
+		first, second := traces[0], traces[1]
+		if first.Reused || !second.Reused {
+			t.Errorf("%s: got Reused %v then %v", scheme, first.Reused, second.Reused)
+		}
+		for i, info := range traces {
+			if info.TTFB <= 0 || info.RemoteIP != "127.0.0.1" {
+				t.Errorf("%s: trace %d got TTFB %v from %q", scheme, i, info.TTFB, info.RemoteIP)
+			}
+		}
+		if (first.TLSHandshake > 0) != (scheme == "https") || second.TLSHandshake != 0 {
+			t.Errorf("%s: got TLSHandshake %v then %v", scheme, first.TLSHandshake, second.TLSHandshake)
+		}
+	}
+}
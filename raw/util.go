@@ -1,7 +1,6 @@
 package raw
 
 import (
-	"compress/gzip"
 	"io"
 	"net/http"
 	"strings"
@@ -21,6 +20,7 @@ func (s *StatusError) Error() string {
 type readCloser struct {
 	io.Reader
 	io.Closer
+	resp *client.Response // the response the body belongs to
 }
 
 func toRequest(method string, path string, query []string, headers map[string][]string, body io.Reader, options *Options) *client.Request {
@@ -39,9 +39,15 @@ func toRequest(method string, path string, query []string, headers map[string][]
 		Version: client.HTTP_1_1,
 		Headers: reqHeaders,
 		Body:    body,
+
+		// without a body, e.g. after a redirect switched to GET, there is nothing to chunk
+		Chunked:         options.Chunked && body != nil,
+		ChunkSizes:      options.ChunkSizes,
+		ChunkExtensions: options.ChunkExtensions,
+		Trailers:        options.Trailers,
 	}
 }
-func toHTTPResponse(conn Conn, resp *client.Response) (*http.Response, error) {
+func toHTTPResponse(conn io.Closer, resp *client.Response, options *Options) (*http.Response, error) {
 	rheaders := fromHeaders(resp.Headers)
 	r := http.Response{
 		ProtoMinor:    resp.Version.Minor,
@@ -52,21 +58,48 @@ func toHTTPResponse(conn Conn, resp *client.Response) (*http.Response, error) {
 		ContentLength: resp.ContentLength(),
 	}
 
-	var err error
-	rbody := resp.Body
-	if headerValue(rheaders, "Content-Encoding") == "gzip" {
-		rbody, err = gzip.NewReader(rbody)
+	rc := &readCloser{resp.Body, conn, resp}
+	if codings := contentCodings(rheaders); len(codings) > 0 && !options.DisableDecompression {
+		rbody, closers, err := decompress(resp.Body, codings)
 		if err != nil {
+			closeAll(closers)
 			return nil, err
 		}
+		if len(closers) > 0 || rbody != resp.Body {
+			rc = &readCloser{rbody, &multiCloser{append(closers, conn)}, resp}
+			// like net/http, drop the headers of the encoded body, Response
+			// keeps them as received
+			r.Uncompressed = true
+			r.ContentLength = -1
+			for k := range r.Header {
+				if strings.EqualFold(k, "Content-Encoding") || strings.EqualFold(k, "Content-Length") {
+					delete(r.Header, k)
+				}
+			}
+		}
 	}
-	rc := &readCloser{rbody, conn}
-
 	r.Body = rc
 
 	return &r, nil
 }
 
+// multiCloser closes all closers in order and returns the first error
+type multiCloser struct {
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	return closeAll(m.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, c := range closers {
+		err = firstErr(err, c.Close())
+	}
+	return err
+}
+
 func toHeaders(h map[string][]string) []client.Header {
 	var r []client.Header
 	for k, v := range h {
@@ -88,6 +121,29 @@ func fromHeaders(h []client.Header) map[string][]string {
 	return r
 }
 
+// responseHeader returns the first value of the header key of resp, matched case-insensitively
+func responseHeader(resp *client.Response, key string) string {
+	for _, h := range resp.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// rewind prepares body to be sent again, it reports false if it can't be
+func rewind(body io.Reader) bool {
+	if body == nil {
+		return true
+	}
+	s, ok := body.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := s.Seek(0, io.SeekStart)
+	return err == nil
+}
+
 func headerValue(headers map[string][]string, key string) string {
 	return strings.Join(headers[key], " ")
 }
@@ -0,0 +1,87 @@
+package request
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RedirectPolicy decides which redirects Request.Do follows, a redirect
+// which isn't followed is returned as the response.
+type RedirectPolicy struct {
+	AllowCrossHost    bool // follow redirects to another host
+	AllowDowngrade    bool // follow redirects from https to http
+	AllowMethodChange bool // follow redirects which turn the request into a GET, like 303 or 302 after a POST
+}
+
+// DefaultRedirectPolicy follows every redirect
+var DefaultRedirectPolicy = RedirectPolicy{
+	AllowCrossHost:    true,
+	AllowDowngrade:    true,
+	AllowMethodChange: true,
+}
+
+// SetRedirectPolicy sets the policy for the redirects followed once
+// SetMaxRedirects allows more than one, DefaultRedirectPolicy if unset.
+func (r *Request) SetRedirectPolicy(p RedirectPolicy) *Request {
+	r.redirectPolicy = &p
+	return r
+}
+
+// redirect turns r into the request following the redirect of resp to
+// location, it reports false if the policy forbids following it.
+func (r *Request) redirect(statusCode int, location []byte) bool {
+	policy := DefaultRedirectPolicy
+	if r.redirectPolicy != nil {
+		policy = *r.redirectPolicy
+	}
+	current := r.Request.URI()
+	next := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(next)
+	current.CopyTo(next)
+	next.UpdateBytes(location)
+
+	crossHost := !bytes.EqualFold(next.Host(), current.Host())
+	downgrade := bytes.Equal(current.Scheme(), []byte("https")) && bytes.Equal(next.Scheme(), []byte("http"))
+	method := string(r.Request.Header.Method())
+	nextMethod := RedirectMethod(statusCode, method)
+	if crossHost && !policy.AllowCrossHost ||
+		downgrade && !policy.AllowDowngrade ||
+		nextMethod != method && !policy.AllowMethodChange {
+		return false
+	}
+
+	if crossHost {
+		// credentials and cookies of the original host must not leak
+		r.Request.Header.Del("Authorization")
+		r.Request.Header.DelAllCookies()
+		r.Request.UseHostHeader = false
+	}
+	if nextMethod != method {
+		r.Method(nextMethod)
+		r.Request.ResetBody()
+		r.Request.PostArgs().Reset()
+		r.Request.Header.Del("Content-Type")
+		r.Request.Header.Del("Content-Length")
+	}
+	r.Request.SetRequestURIBytes(next.FullURI())
+	return true
+}
+
+// RedirectMethod returns the method of the request following a redirect
+// with statusCode of a method request, like browsers do. The raw client
+// uses it too.
+func RedirectMethod(statusCode int, method string) string {
+	switch statusCode {
+	case fasthttp.StatusSeeOther:
+		if !strings.EqualFold(method, MethodHead) {
+			return MethodGet
+		}
+	case fasthttp.StatusMovedPermanently, fasthttp.StatusFound:
+		if strings.EqualFold(method, MethodPost) {
+			return MethodGet
+		}
+	}
+	return method
+}
@@ -0,0 +1,131 @@
+package request
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echo answers with the method, body, content type and credentials it got
+func echo(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	fmt.Fprintf(w, "%s %s body=%q type=%q auth=%q cookie=%q",
+		r.Method, r.URL.Path, b, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), r.Header.Get("Cookie"))
+}
+
+func TestRedirectPostToGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/"})
+			http.Redirect(w, r, "/home", http.StatusFound)
+			return
+		}
+		echo(w, r)
+	}))
+	defer srv.Close()
+
+	var traces []TraceInfo
+	req, resp := AcquireRequestResponse()
+	defer ReleaseRequest(req)
+	defer ReleaseResponse(resp)
+	req.Trace = &traces
+	if err := req.Post(srv.URL+"/login", Data{"user": "admin"}).SetMaxRedirects(5).Do(resp); err != nil {
+		t.Fatal(err)
+	}
+	// the cookie set by the first hop is sent by the second one
+	if got, want := resp.Text(), `GET /home body="" type="" auth="" cookie="sid=1"`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("got %d traces, want one per hop", len(traces))
+	}
+	if !strings.HasPrefix(traces[0].Request, "POST /login") || !strings.HasPrefix(traces[1].Request, "GET /home") {
+		t.Errorf("got traces of %q and %q", traces[0].Request, traces[1].Request)
+	}
+	if !strings.Contains(traces[0].Response, "302") {
+		t.Errorf("got first response %q", traces[0].Response)
+	}
+}
+
+func TestRedirectCrossHostDropsCredentials(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(echo))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cross":
+			http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+		case "/same":
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		default:
+			echo(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for path, want := range map[string]string{
+		"/cross": `auth="" cookie=""`,
+		"/same":  `auth="Basic dTpw" cookie="a=1"`,
+	} {
+		req, resp := AcquireRequestResponse()
+		req.Get(srv.URL+path).SetMaxRedirects(5).BasicAuth("u", "p")
+		req.Request.Header.SetCookie("a", "1")
+		if err := req.Do(resp); err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Text(); !strings.HasSuffix(got, want) {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+		ReleaseRequest(req)
+		ReleaseResponse(resp)
+	}
+}
+
+func TestRedirectPolicyRefusals(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(echo))
+	defer plain.Close()
+	tls := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/downgrade":
+			http.Redirect(w, r, plain.URL+"/landing", http.StatusFound)
+		case "/cross":
+			http.Redirect(w, r, strings.Replace(r.Header.Get("X-Self"), "127.0.0.1", "localhost", 1)+"/landing", http.StatusFound)
+		case "/post":
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		default:
+			echo(w, r)
+		}
+	}))
+	defer tls.Close()
+
+	for _, tc := range []struct {
+		name   string
+		policy RedirectPolicy
+		method string
+		path   string
+	}{
+		{"downgrade", RedirectPolicy{AllowCrossHost: true, AllowMethodChange: true}, MethodGet, "/downgrade"},
+		{"cross host", RedirectPolicy{AllowDowngrade: true, AllowMethodChange: true}, MethodGet, "/cross"},
+		{"method change", RedirectPolicy{AllowCrossHost: true, AllowDowngrade: true}, MethodPost, "/post"},
+	} {
+		for _, allowed := range []bool{false, true} {
+			policy := tc.policy
+			if allowed {
+				policy = DefaultRedirectPolicy
+			}
+			req, resp := AcquireRequestResponse()
+			req.Method(tc.method).prepare(tls.URL + tc.path)
+			req.SetHeader(Header{"X-Self": tls.URL})
+			if err := req.SetMaxRedirects(5).SetRedirectPolicy(policy).Do(resp); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if got := resp.StatusCode(); (got == http.StatusFound) == allowed {
+				t.Errorf("%s: got status %d with the redirect allowed %v", tc.name, got, allowed)
+			}
+			ReleaseRequest(req)
+			ReleaseResponse(resp)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package request
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/12end/tls"
@@ -24,6 +25,19 @@ type Params map[string]string
 type Data map[string]string // for post form
 type Header map[string]string
 type Files map[string]File // name ,file-content
+
+// Pair is a single key and value of the ordered parameter types
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// ParamList, DataList and HeaderList are like Params, Data and Header but
+// keep their order on the wire and may repeat a key, e.g. id=1&id=2.
+type ParamList []Pair
+type DataList []Pair
+type HeaderList []Pair
+
 type File struct {
 	FileName    string
 	ContentType string
@@ -85,29 +99,75 @@ type TraceInfo struct {
 	Request  string
 	Response string
 	Duration time.Duration
+
+	DNSLookup    time.Duration // resolving the host, zero if the connection was reused, dialed by a proxy or the address cached
+	Connect      time.Duration // TCP connect, including the proxy handshake if any
+	TLSHandshake time.Duration
+	TTFB         time.Duration // from sending the request to the first byte of the response
+	BodyRead     time.Duration // from the first byte to the end of the response
+	RemoteIP     string        // the address connected to, which is the proxy if one is used
+	Reused       bool          // whether the connection had been used by a previous request
+	Attempt      int           // the attempt of the exchange counting from 1, see RetryPolicy
 }
 
 type Request struct {
 	*fasthttp.Request
-	Trace        *[]TraceInfo
-	maxRedirects int
-	Jar          *cookiejar.Jar
-	client       *fasthttp.Client
+	Trace          *[]TraceInfo
+	maxRedirects   int
+	redirectPolicy *RedirectPolicy
+	retryPolicy    *RetryPolicy
+	limiter        *Limiter
+	Jar            *cookiejar.Jar
+	jar            http.CookieJar // of the Session, replaces Jar if set
+	client         *fasthttp.Client
+	proxy          string
+	baseURL        string
+	timeout        time.Duration // set on the fasthttp.Request too, which has no getter
+	err            error         // deferred error of a builder method, returned by Do
 }
 
 func (r *Request) Reset() {
 	r.Trace = nil
 	r.maxRedirects = 0
+	r.redirectPolicy = nil
+	r.retryPolicy = nil
+	r.limiter = nil
 	r.Jar = nil
+	r.jar = nil
+	r.proxy = ""
+	r.baseURL = ""
+	r.timeout = 0
+	r.err = nil
+	r.client = &defaultClient
 	fasthttp.ReleaseRequest(r.Request)
 	r.Request = nil
 }
 
+// fail defers err to the next Do unless an earlier builder method already failed
+func (r *Request) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
 func (r *Request) SetMaxRedirects(t int) *Request {
 	r.maxRedirects = t
 	return r
 }
 
+// SetRetryPolicy retries failed attempts of the request according to p,
+// every attempt is traced.
+func (r *Request) SetRetryPolicy(p RetryPolicy) *Request {
+	r.retryPolicy = &p
+	return r
+}
+
+// SetLimiter throttles the request, every attempt and redirect hop with l
+func (r *Request) SetLimiter(l *Limiter) *Request {
+	r.limiter = l
+	return r
+}
+
 func (r *Request) String() string {
 	return r.Request.String()
 }
@@ -117,8 +177,10 @@ func (r *Request) Method(method string) *Request {
 	return r
 }
 
+// URI sets the URI of the request, relative ones resolve against the base
+// URL of the Session the request comes from.
 func (r *Request) URI(u string) *Request {
-	r.Request.SetRequestURI(u)
+	r.Request.SetRequestURI(resolveURL(r.baseURL, u))
 	return r
 }
 
@@ -140,7 +202,17 @@ func (r *Request) SetParams(p Params) *Request {
 	return r
 }
 
+// SetParamList replaces the query with p, in order and with repeated keys
+func (r *Request) SetParamList(p ParamList) *Request {
+	r.Request.URI().QueryArgs().Reset()
+	for _, kv := range p {
+		r.Request.URI().QueryArgs().Add(kv.Key, kv.Value)
+	}
+	return r
+}
+
 func (r *Request) SetTimeout(t time.Duration) *Request {
+	r.timeout = t
 	r.Request.SetTimeout(t)
 	return r
 }
@@ -155,6 +227,17 @@ func (r *Request) SetData(p Data) *Request {
 	return r
 }
 
+// SetDataList replaces the form body with p, in order and with repeated keys
+func (r *Request) SetDataList(p DataList) *Request {
+	r.ContentType("application/x-www-form-urlencoded")
+	r.ResetBody()
+	r.PostArgs().Reset()
+	for _, kv := range p {
+		r.Request.PostArgs().Add(kv.Key, kv.Value)
+	}
+	return r
+}
+
 func (r *Request) DisableNormalizing() *Request {
 	r.Request.Header.DisableNormalizing()
 	r.Request.URI().DisablePathNormalizing = true
@@ -209,6 +292,18 @@ func (r *Request) SetHeader(h Header) *Request {
 	return r
 }
 
+// SetHeaderList replaces the headers named in h with its values, which are
+// sent in order and repeated for repeated keys.
+func (r *Request) SetHeaderList(h HeaderList) *Request {
+	for _, kv := range h {
+		r.Header.Del(kv.Key)
+	}
+	for _, kv := range h {
+		r.Header.Add(kv.Key, kv.Value)
+	}
+	return r
+}
+
 func (r *Request) WithTrace() *Request {
 	r.Trace = &[]TraceInfo{}
 	return r
@@ -263,13 +358,87 @@ func escapeQuotes(s string) string {
 }
 
 func (r *Request) Do(resp *Response) error {
+	return r.DoContext(context.Background(), resp)
+}
+
+// DoContext is like Do but returns as soon as ctx is done, the returned
+// error then wraps ctx.Err().
+//
+// If SetMaxRedirects allows more than one redirect, redirects allowed by the
+// redirect policy are followed, every hop being traced and storing its
+// cookies in Jar, or the Session r comes from. r is left as the request of
+// the last hop.
+func (r *Request) DoContext(ctx context.Context, resp *Response) error {
+	if err := r.err; err != nil {
+		// the next build of r starts afresh
+		r.err = nil
+		return err
+	}
+	for redirects := 0; ; redirects++ {
+		if err := r.retry(ctx, resp); err != nil {
+			return err
+		}
+		statusCode := resp.StatusCode()
+		if r.maxRedirects <= 1 || !fasthttp.StatusCodeIsRedirect(statusCode) {
+			return nil
+		}
+		if redirects >= r.maxRedirects {
+			return fasthttp.ErrTooManyRedirects
+		}
+		location := resp.Header.Peek("Location")
+		if len(location) == 0 {
+			return fasthttp.ErrMissingLocation
+		}
+		if !r.redirect(statusCode, location) {
+			return nil
+		}
+	}
+}
+
+// retry sends the request until an attempt succeeds or the retry policy
+// gives up.
+func (r *Request) retry(ctx context.Context, resp *Response) error {
+	for attempt := 1; ; attempt++ {
+		err := r.exchange(ctx, resp, attempt)
+		p := r.retryPolicy
+		if p == nil || attempt >= p.MaxAttempts || !p.ShouldRetry(string(r.Header.Method()), resp.StatusCode(), err) {
+			return err
+		}
+		var retryAfter string
+		if err == nil {
+			retryAfter = string(resp.Header.Peek("Retry-After"))
+		}
+		if err := p.Wait(ctx, attempt, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// exchange sends the request once, applying the cookies of Jar and storing
+// the ones set by the response.
+func (r *Request) exchange(ctx context.Context, resp *Response, attempt int) error {
+	if r.limiter != nil {
+		release, err := r.limiter.Acquire(ctx, string(r.Request.URI().Host()))
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
 	resp.body = ""
 	resp.title = ""
-	u, err := url.Parse(string(r.Request.Header.RequestURI()))
+	resp.charset = ""
+	resp.json = nil
+	resp.doc = nil
+	resp.url = r.Request.URI().String()
+	u, err := url.Parse(resp.url)
 	if err == nil {
-		if r.Jar.Cookies(u) != nil {
+		var jar http.CookieJar = r.Jar
+		if r.jar != nil {
+			jar = r.jar
+		}
+		if jar.Cookies(u) != nil {
 			r.Header.DelAllCookies()
-			cookies := r.Jar.Cookies(u)
+			cookies := jar.Cookies(u)
 			for _, c := range cookies {
 				r.Header.SetCookie(c.Name, c.Value)
 			}
@@ -280,7 +449,7 @@ func (r *Request) Do(resp *Response) error {
 				resp.Header.VisitAllCookie(func(key, value []byte) {
 					httpResp.Header.Add("Set-Cookie", string(value))
 				})
-				r.Jar.SetCookies(u, httpResp.Cookies())
+				jar.SetCookies(u, httpResp.Cookies())
 			}
 		}()
 	}
@@ -288,17 +457,73 @@ func (r *Request) Do(resp *Response) error {
 	start := time.Now()
 	defer func() {
 		if r.Trace != nil {
-			*r.Trace = append(*r.Trace, TraceInfo{
+			info := TraceInfo{
 				Request:  r.String(),
 				Response: resp.String(),
 				Duration: time.Since(start),
-			})
+				Attempt:  attempt,
+			}
+			if addr, ok := resp.RemoteAddr().(*traceAddr); ok {
+				addr.fill(&info)
+			}
+			*r.Trace = append(*r.Trace, info)
 		}
 	}()
-	if r.maxRedirects > 1 {
-		return r.client.DoRedirects(r.Request, resp.Response, r.maxRedirects)
+	return r.send(ctx, resp)
+}
+
+// send sends the request with the client it is configured with. If ctx can
+// be canceled, the exchange runs on copies of the request and response so
+// that it can be abandoned once ctx is done.
+func (r *Request) send(ctx context.Context, resp *Response) error {
+	c := r.getClient()
+	if ctx.Done() == nil {
+		return doClient(c, r.Request, resp.Response, time.Time{})
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("request canceled: %w", err)
+	}
+
+	// CopyTo leaves the timeout behind, it bounds the deadline instead
+	req := fasthttp.AcquireRequest()
+	res := fasthttp.AcquireResponse()
+	r.Request.CopyTo(req)
+	deadline, _ := ctx.Deadline()
+	if r.timeout > 0 {
+		if d := time.Now().Add(r.timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	ch := make(chan error, 1)
+	go func() {
+		ch <- doClient(c, req, res, deadline)
+	}()
+
+	select {
+	case err := <-ch:
+		res.CopyTo(resp.Response)
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(res)
+		if err != nil && ctx.Err() != nil {
+			// the deadline of ctx was hit by fasthttp first
+			return fmt.Errorf("request canceled: %w", ctx.Err())
+		}
+		return err
+	case <-ctx.Done():
+		go func() {
+			<-ch
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(res)
+		}()
+		return fmt.Errorf("request canceled: %w", ctx.Err())
+	}
+}
+
+func doClient(c *fasthttp.Client, req *fasthttp.Request, resp *fasthttp.Response, deadline time.Time) error {
+	if !deadline.IsZero() {
+		return c.DoDeadline(req, resp, deadline)
 	} else {
-		return r.client.Do(r.Request, resp.Response)
+		return c.Do(req, resp)
 	}
 }
 
@@ -334,6 +559,12 @@ func (r *Request) prepare(u string, args ...interface{}) *Request {
 			r.SetParams(arg.(Params))
 		case Data:
 			r.SetData(arg.(Data))
+		case HeaderList:
+			r.SetHeaderList(arg.(HeaderList))
+		case ParamList:
+			r.SetParamList(arg.(ParamList))
+		case DataList:
+			r.SetDataList(arg.(DataList))
 		}
 	}
 	return r
@@ -0,0 +1,44 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestTimeoutWithContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	req, resp := AcquireRequestResponse()
+	defer ReleaseRequest(req)
+	defer ReleaseResponse(resp)
+	req.SetTimeout(50 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, do := range []struct {
+		name string
+		do   func() error
+	}{
+		{"DoContext", func() error { return req.Get(srv.URL).DoContext(ctx, resp) }},
+		// the timeout survives the exchange on a copy
+		{"Do", func() error { return req.Get(srv.URL).Do(resp) }},
+	} {
+		start := time.Now()
+		err := do.do()
+		if !errors.Is(err, fasthttp.ErrTimeout) {
+			t.Errorf("%s: got %v, want a timeout", do.name, err)
+		}
+		if d := time.Since(start); d > 200*time.Millisecond {
+			t.Errorf("%s: took %v", do.name, d)
+		}
+	}
+}
@@ -3,14 +3,11 @@ package request
 import (
 	"bytes"
 	"github.com/valyala/fasthttp"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
-	"html"
-	"io"
+	"golang.org/x/net/html"
+	stdhtml "html"
 	"regexp"
 	"strings"
 	"sync"
-	"unicode/utf8"
 )
 
 var responsePool sync.Pool
@@ -36,8 +33,12 @@ func ReleaseResponse(resp *Response) {
 
 type Response struct {
 	*fasthttp.Response
-	body  string
-	title string
+	body    string
+	title   string
+	charset string       // of the body, see Charset
+	json    *interface{} // the parsed body, see JSONPath
+	doc     *html.Node   // the parsed body, see Document
+	url     string       // of the request answered, see URL
 }
 
 func (r *Response) Reset() {
@@ -45,6 +46,17 @@ func (r *Response) Reset() {
 	r.Response = nil
 	r.title = ""
 	r.body = ""
+	r.charset = ""
+	r.json = nil
+	r.doc = nil
+	r.url = ""
+}
+
+// URL returns the URL of the request the response answers, which is the
+// last hop if redirects were followed. It is empty if the response wasn't
+// filled by Request.Do.
+func (r *Response) URL() string {
+	return r.url
 }
 
 func (r *Response) GetHeader(k string) (string, bool) {
@@ -64,7 +76,8 @@ func (r *Response) Text() string {
 	if err != nil {
 		body = r.Response.Body()
 	}
-	r.body = string(decodeBody(body))
+	decoded, charset := decodeBody(body, string(r.Response.Header.ContentType()))
+	r.body, r.charset = string(decoded), charset
 	return r.body
 }
 
@@ -75,7 +88,7 @@ func (r *Response) Title() string {
 	find := titleReg.FindStringSubmatch(r.Text())
 	if len(find) > 1 {
 		r.title = find[1]
-		r.title = emptyReg.ReplaceAllString(html.UnescapeString(r.title), "")
+		r.title = emptyReg.ReplaceAllString(stdhtml.UnescapeString(r.title), "")
 		r.title = strings.TrimSpace(r.title)
 	}
 	return r.title
@@ -115,19 +128,3 @@ func (r *Response) Search(reg *regexp.Regexp) map[string]string {
 	}
 	return result
 }
-
-func decodeBody(s []byte) []byte {
-	I := bytes.NewReader(s)
-	var O io.Reader
-	if !utf8.Valid(s) {
-		O = transform.NewReader(I, simplifiedchinese.GB18030.NewDecoder())
-		d, e := io.ReadAll(O)
-		if e != nil {
-			return s
-		} else {
-			return d
-		}
-	} else {
-		return s
-	}
-}
@@ -0,0 +1,155 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RetryPolicy decides whether a failed attempt of a request is retried and
+// how long to wait before. It is used by Request and the raw client.
+type RetryPolicy struct {
+	MaxAttempts        int           // attempts including the first one, no retry if 1 or less
+	Backoff            time.Duration // delay before the first retry, doubled for each following one
+	MaxBackoff         time.Duration // caps the delay, Retry-After included, unlimited if zero
+	Jitter             float64       // fraction of the delay added or removed at random, 0.2 for ±20%
+	RetryNetworkErrors bool          // retry on refused or reset connections and the like
+	RetryTimeouts      bool          // retry on dial, read and write timeouts
+	RetryStatusCodes   []int         // retry on these statuses, honoring Retry-After
+	// RetryUnsafeMethods retries network errors and timeouts of methods
+	// other than GET, HEAD, OPTIONS and TRACE too, although the failed
+	// attempt may have reached the server and had side effects.
+	RetryUnsafeMethods bool
+}
+
+// DefaultRetryPolicy retries twice on network errors and timeouts of safe
+// methods and on 429 and 503 responses.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:        3,
+	Backoff:            500 * time.Millisecond,
+	MaxBackoff:         10 * time.Second,
+	Jitter:             0.2,
+	RetryNetworkErrors: true,
+	RetryTimeouts:      true,
+	RetryStatusCodes:   []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+}
+
+// ShouldRetry reports whether an attempt of a method request which ended
+// with err, or with a response of statusCode if err is nil, should be
+// retried. Errors of a done context are never retried.
+func (p *RetryPolicy) ShouldRetry(method string, statusCode int, err error) bool {
+	if err == nil {
+		for _, code := range p.RetryStatusCodes {
+			if code == statusCode {
+				return true
+			}
+		}
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if !p.RetryUnsafeMethods && !isSafe(method) {
+		return false
+	}
+	if isTimeout(err) {
+		return p.RetryTimeouts
+	}
+	return p.RetryNetworkErrors && isNetworkError(err)
+}
+
+// Delay returns how long to wait before retrying the attempt-th attempt,
+// counting from 1. retryAfter is the Retry-After header of its response.
+func (p *RetryPolicy) Delay(attempt int, retryAfter string) time.Duration {
+	if d, ok := parseRetryAfter(retryAfter); ok {
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return d
+	}
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// Wait sleeps for the delay before retrying the attempt-th attempt, it
+// returns an error wrapping ctx.Err() if ctx is done first.
+func (p *RetryPolicy) Wait(ctx context.Context, attempt int, retryAfter string) error {
+	timer := time.NewTimer(p.Delay(attempt, retryAfter))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("retry canceled: %w", ctx.Err())
+	}
+}
+
+// parseRetryAfter parses the delay in seconds or the date of a Retry-After header
+func parseRetryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := time.Until(t); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// isSafe reports whether a request of method can be replayed after it may
+// have reached the server, like net/http's Transport does.
+func isSafe(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodOptions, fasthttp.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	if errors.As(err, &t) && t.Timeout() {
+		return true
+	}
+	return errors.Is(err, fasthttp.ErrDialTimeout) || errors.Is(err, fasthttp.ErrTLSHandshakeTimeout)
+}
+
+func isNetworkError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, fasthttp.ErrConnectionClosed)
+}
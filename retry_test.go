@@ -0,0 +1,127 @@
+package request
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		60: time.Second,
+	} {
+		if d := p.Delay(attempt, ""); d != want {
+			t.Errorf("attempt %d: got %v, want %v", attempt, d, want)
+		}
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(2, ""); d < 160*time.Millisecond || d > 240*time.Millisecond {
+			t.Fatalf("got %v, want 200ms ±20%%", d)
+		}
+	}
+
+	for _, tc := range []struct {
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"0", 0, 0},
+		{" 1 ", time.Second, time.Second},
+		{"30", time.Second, time.Second}, // capped by MaxBackoff
+		{time.Now().Add(800 * time.Millisecond).UTC().Format(http.TimeFormat), 0, 800 * time.Millisecond},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+		{"-1", 160 * time.Millisecond, 240 * time.Millisecond}, // invalid, the backoff applies
+		{"soon", 160 * time.Millisecond, 240 * time.Millisecond},
+	} {
+		if d := p.Delay(2, tc.retryAfter); d < tc.min || d > tc.max {
+			t.Errorf("Retry-After %q: got %v, want between %v and %v", tc.retryAfter, d, tc.min, tc.max)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryShouldRetry(t *testing.T) {
+	p := DefaultRetryPolicy
+	reset := fmt.Errorf("read: %w", syscall.ECONNRESET)
+	for _, tc := range []struct {
+		method string
+		status int
+		err    error
+		want   bool
+	}{
+		{"GET", 503, nil, true},
+		{"POST", 429, nil, true},
+		{"GET", 500, nil, false},
+		{"GET", 0, reset, true},
+		{"HEAD", 0, timeoutError{}, true},
+		{"GET", 0, fasthttp.ErrDialTimeout, true},
+		{"POST", 0, reset, false},
+		{"PATCH", 0, timeoutError{}, false},
+		{"PUT", 0, io.ErrUnexpectedEOF, false},
+		{"GET", 0, fmt.Errorf("request canceled: %w", context.Canceled), false},
+		{"GET", 0, fmt.Errorf("malformed"), false},
+	} {
+		if got := p.ShouldRetry(tc.method, tc.status, tc.err); got != tc.want {
+			t.Errorf("%s %d %v: got %v", tc.method, tc.status, tc.err, got)
+		}
+	}
+
+	p.RetryUnsafeMethods = true
+	if !p.ShouldRetry("POST", 0, reset) {
+		t.Error("RetryUnsafeMethods didn't retry a POST")
+	}
+	p.RetryTimeouts = false
+	if p.ShouldRetry("GET", 0, timeoutError{}) {
+		t.Error("timeout retried with RetryTimeouts off")
+	}
+}
+
+func TestRetryOnlySafeMethods(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		// the connection drops without a response
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	p := DefaultRetryPolicy
+	p.Backoff = time.Millisecond
+	for method, want := range map[string]int32{"GET": 3, "POST": 1} {
+		atomic.StoreInt32(&attempts, 0)
+		req, resp := AcquireRequestResponse()
+		req.SetRetryPolicy(p)
+		req.Request.Header.SetMethod(method)
+		req.Request.SetRequestURI(srv.URL)
+		if err := req.Do(resp); err == nil {
+			t.Errorf("%s: no error", method)
+		}
+		if n := atomic.LoadInt32(&attempts); n != want {
+			t.Errorf("%s: sent %d times, want %d", method, n, want)
+		}
+		ReleaseRequest(req)
+		ReleaseResponse(resp)
+	}
+}
@@ -0,0 +1,374 @@
+package request
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Session hands out Requests sharing a configuration and a cookie jar. The
+// cookies can be exported and imported to replay a login across scan runs.
+// It implements http.CookieJar and is safe for concurrent use, but its
+// fields must be set before it is used. The zero Session is ready to use.
+type Session struct {
+	// BaseURL is what relative URIs of the Requests resolve against. Dot
+	// segments are kept, so path traversal payloads survive along with
+	// Request.DisableNormalizing.
+	BaseURL string
+	// Headers are set on every Request
+	Headers   Header
+	UserAgent string
+	// Username and Password are sent with basic auth if Username is set
+	Username string
+	Password string
+	Timeout  time.Duration
+	// MaxRedirects and RedirectPolicy configure redirects like
+	// Request.SetMaxRedirects and Request.SetRedirectPolicy
+	MaxRedirects   int
+	RedirectPolicy *RedirectPolicy
+	RetryPolicy    *RetryPolicy
+	Limiter        *Limiter
+	Proxy          string
+	// Client sends the Requests, the default client if nil
+	Client *fasthttp.Client
+
+	mu      sync.Mutex
+	jar     *cookiejar.Jar // created on first use, see init
+	cookies map[cookieKey]*Cookie
+}
+
+// Cookie is a cookie stored in a Session
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	HostOnly bool      `json:"hostOnly"` // only sent to Domain, not to its subdomains
+	Path     string    `json:"path"`
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"httpOnly"`
+	Expires  time.Time `json:"expires"` // zero for session cookies
+}
+
+type cookieKey struct {
+	domain, path, name string
+}
+
+// NewSession returns a Session without configuration or cookies
+func NewSession() *Session {
+	return &Session{}
+}
+
+// init creates the cookie storage, s.mu must be held
+func (s *Session) init() {
+	if s.jar == nil {
+		s.jar, _ = cookiejar.New(nil)
+		s.cookies = map[cookieKey]*Cookie{}
+	}
+}
+
+// AcquireRequest returns a Request from the request pool configured by the
+// session and using its cookies.
+func (s *Session) AcquireRequest() *Request {
+	r := AcquireRequest()
+	r.jar = s
+	r.baseURL = s.BaseURL
+	r.Client(s.Client)
+	r.SetHeader(s.Headers)
+	if s.UserAgent != "" {
+		r.UserAgent(s.UserAgent)
+	}
+	if s.Username != "" {
+		r.BasicAuth(s.Username, s.Password)
+	}
+	if s.Timeout > 0 {
+		r.SetTimeout(s.Timeout)
+	}
+	r.SetMaxRedirects(s.MaxRedirects)
+	if s.RedirectPolicy != nil {
+		r.SetRedirectPolicy(*s.RedirectPolicy)
+	}
+	if s.RetryPolicy != nil {
+		r.SetRetryPolicy(*s.RetryPolicy)
+	}
+	if s.Limiter != nil {
+		r.SetLimiter(s.Limiter)
+	}
+	if s.Proxy != "" {
+		r.Proxy(s.Proxy)
+	}
+	return r
+}
+
+// AcquireRequestResponse is like AcquireRequest but also returns a Response
+// from the response pool.
+func (s *Session) AcquireRequestResponse() (*Request, *Response) {
+	return s.AcquireRequest(), AcquireResponse()
+}
+
+// Cookies implements http.CookieJar
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	s.mu.Lock()
+	s.init()
+	jar := s.jar
+	s.mu.Unlock()
+	return jar.Cookies(u)
+}
+
+// SetCookies implements http.CookieJar
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	s.jar.SetCookies(u, cookies)
+
+	host := canonicalHost(u.Host)
+	now := time.Now()
+	for _, c := range cookies {
+		sc := &Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   host,
+			HostOnly: true,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if c.Domain != "" {
+			domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+			if host != domain && (!strings.HasSuffix(host, "."+domain) || net.ParseIP(host) != nil) {
+				// rejected by the jar
+				continue
+			}
+			sc.Domain, sc.HostOnly = domain, false
+		}
+		if !strings.HasPrefix(sc.Path, "/") {
+			sc.Path = defaultPath(u.Path)
+		}
+		key := cookieKey{sc.Domain, sc.Path, sc.Name}
+		switch {
+		case c.MaxAge < 0:
+			delete(s.cookies, key)
+			continue
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			if !c.Expires.After(now) {
+				delete(s.cookies, key)
+				continue
+			}
+			sc.Expires = c.Expires
+		}
+		s.cookies[key] = sc
+	}
+}
+
+// AllCookies returns the unexpired cookies of the session ordered by
+// domain, path and name.
+func (s *Session) AllCookies() []Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var cookies []Cookie
+	for key, c := range s.cookies {
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(s.cookies, key)
+			continue
+		}
+		cookies = append(cookies, *c)
+	}
+	sort.Slice(cookies, func(i, j int) bool {
+		a, b := cookies[i], cookies[j]
+		if a.Domain != b.Domain {
+			return a.Domain < b.Domain
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Name < b.Name
+	})
+	return cookies
+}
+
+// AddCookies adds cookies to the session as if they were set by their domain
+func (s *Session) AddCookies(cookies ...Cookie) {
+	for _, c := range cookies {
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		hc := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if !c.HostOnly {
+			hc.Domain = c.Domain
+		}
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		s.SetCookies(&url.URL{Scheme: scheme, Host: c.Domain, Path: path}, []*http.Cookie{hc})
+	}
+}
+
+// ExportJSON writes the cookies of the session as a JSON array of Cookie
+func (s *Session) ExportJSON(w io.Writer) error {
+	cookies := s.AllCookies()
+	if cookies == nil {
+		cookies = []Cookie{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cookies)
+}
+
+// ImportJSON adds the cookies of a JSON array written by ExportJSON
+func (s *Session) ImportJSON(r io.Reader) error {
+	var cookies []Cookie
+	if err := json.NewDecoder(r).Decode(&cookies); err != nil {
+		return fmt.Errorf("could not decode cookies: %w", err)
+	}
+	s.AddCookies(cookies...)
+	return nil
+}
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ExportNetscape writes the cookies of the session in the Netscape
+// cookies.txt format used by curl and wget.
+func (s *Session) ExportNetscape(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, c := range s.AllCookies() {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!c.HostOnly), c.Path, netscapeBool(c.Secure), expires, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+// ImportNetscape adds the cookies of a Netscape cookies.txt file
+func (s *Session) ImportNetscape(r io.Reader) error {
+	var cookies []Cookie
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) == 6 {
+			// cookies without value
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return fmt.Errorf("invalid cookie on line %d: %d fields", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cookie expiry on line %d: %w", line, err)
+		}
+		c := Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	s.AddCookies(cookies...)
+	return nil
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// canonicalHost strips the port of host and lowercases it
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// defaultPath is the cookie path for a request to path, see RFC 6265 5.1.4
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// resolveURL resolves ref against base without removing dot segments, which
+// are left to the server.
+func resolveURL(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil || !b.IsAbs() {
+		return ref
+	}
+	origin := b.Scheme + "://" + b.Host
+	switch {
+	case strings.HasPrefix(ref, "//"):
+		return b.Scheme + ":" + ref
+	case strings.HasPrefix(ref, "/"):
+		return origin + ref
+	case ref == "":
+		return base
+	case strings.HasPrefix(ref, "?"):
+		return origin + b.EscapedPath() + ref
+	default:
+		dir := b.EscapedPath()
+		if i := strings.LastIndex(dir, "/"); i >= 0 {
+			dir = dir[:i]
+		}
+		return origin + dir + "/" + ref
+	}
+}
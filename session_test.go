@@ -0,0 +1,69 @@
+package request
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionZeroValue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("sid"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Path: "/"})
+			return
+		}
+		w.Write([]byte("logged in"))
+	}))
+	defer srv.Close()
+
+	s := &Session{BaseURL: srv.URL}
+	for i := 0; i < 2; i++ {
+		req, resp := s.AcquireRequestResponse()
+		if err := req.Get("/x").Do(resp); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && resp.Text() != "logged in" {
+			t.Errorf("cookie not sent back, got %q", resp.Text())
+		}
+		ReleaseRequest(req)
+		ReleaseResponse(resp)
+	}
+	if n := len(s.AllCookies()); n != 1 {
+		t.Errorf("got %d cookies, want 1", n)
+	}
+}
+
+func TestSessionExportImport(t *testing.T) {
+	var s Session
+	s.AddCookies(
+		Cookie{Name: "a", Value: "1", Domain: "example.com", Path: "/", HostOnly: true},
+		Cookie{Name: "b", Value: "2", Domain: "example.com", Path: "/app", Secure: true, HttpOnly: true},
+	)
+	for _, format := range []struct {
+		name   string
+		export func(*Session, *bytes.Buffer) error
+		load   func(*Session, *bytes.Buffer) error
+	}{
+		{"json", func(s *Session, b *bytes.Buffer) error { return s.ExportJSON(b) }, func(s *Session, b *bytes.Buffer) error { return s.ImportJSON(b) }},
+		{"netscape", func(s *Session, b *bytes.Buffer) error { return s.ExportNetscape(b) }, func(s *Session, b *bytes.Buffer) error { return s.ImportNetscape(b) }},
+	} {
+		var b bytes.Buffer
+		if err := format.export(&s, &b); err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		var imported Session
+		if err := format.load(&imported, &b); err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		got, want := imported.AllCookies(), s.AllCookies()
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d cookies, want %d", format.name, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: got %+v, want %+v", format.name, got[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// traceDialer wraps dial so that the connections it returns measure the
+// timings of the requests sent over them. If dial is nil, the host is
+// resolved and connected to separately to time both steps, over IPv6 as well
+// if dualStack is set, within timeout like fasthttp's own dialer.
+func traceDialer(dial fasthttp.DialFunc, dualStack bool, timeout time.Duration) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		c := &traceConn{}
+		start := time.Now()
+		var (
+			conn net.Conn
+			err  error
+		)
+		if dial != nil {
+			conn, err = dial(addr)
+			c.connect = time.Since(start)
+		} else {
+			conn, err = c.dial(addr, dualStack, timeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Conn = conn
+		c.dialed = time.Now()
+		return c, nil
+	}
+}
+
+// traceConn records the dial timings of a connection and when bytes of the
+// current exchange are read. fasthttp asks for the remote address once at
+// the start of every exchange, which is where a new one starts.
+type traceConn struct {
+	net.Conn
+	dns, connect time.Duration
+	dialed       time.Time
+	handshake    bool // whether bytes were written before the first exchange
+	exchanges    int
+	current      *traceAddr
+}
+
+func (c *traceConn) dial(addr string, dualStack bool, timeout time.Duration) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	ips, cached, err := lookupIP(ctx, host)
+	if !cached {
+		c.dns = time.Since(start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// like fasthttp, dial IPv4 only unless dual stack is enabled and try
+	// every address until one answers
+	start = time.Now()
+	defer func() { c.connect = time.Since(start) }()
+	network := "tcp4"
+	if dualStack {
+		network = "tcp"
+	}
+	err = errors.New("no IPv4 address found for " + host)
+	var d net.Dialer
+	for _, ip := range ips {
+		if !dualStack && ip.To4() == nil {
+			continue
+		}
+		var conn net.Conn
+		if conn, err = d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, fasthttp.ErrDialTimeout
+		}
+	}
+	return nil, err
+}
+
+// resolved caches the addresses looked up by the traced dialer for as long
+// as fasthttp's dialer caches them.
+var resolved sync.Map // host -> resolvedHost
+
+type resolvedHost struct {
+	ips []net.IP
+	at  time.Time
+}
+
+// lookupIP returns the addresses of host, cached reports whether they
+// weren't looked up now.
+func lookupIP(ctx context.Context, host string) (ips []net.IP, cached bool, err error) {
+	if v, ok := resolved.Load(host); ok {
+		if e := v.(resolvedHost); time.Since(e.at) < fasthttp.DefaultDNSCacheDuration {
+			return e.ips, true, nil
+		}
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	resolved.Store(host, resolvedHost{ips: ips, at: time.Now()})
+	return ips, false, nil
+}
+
+func (c *traceConn) RemoteAddr() net.Addr {
+	now := time.Now()
+	addr := &traceAddr{Addr: c.Conn.RemoteAddr(), start: now}
+	if c.exchanges == 0 {
+		addr.dns = c.dns
+		addr.connect = c.connect
+		if c.handshake {
+			addr.tlsHandshake = now.Sub(c.dialed)
+		}
+	} else {
+		addr.reused = true
+	}
+	c.exchanges++
+	c.current = addr
+	return addr
+}
+
+func (c *traceConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.current != nil {
+		now := time.Now()
+		if c.current.firstByte.IsZero() {
+			c.current.firstByte = now
+		}
+		c.current.lastByte = now
+	}
+	return n, err
+}
+
+func (c *traceConn) Write(b []byte) (int, error) {
+	if c.exchanges == 0 {
+		c.handshake = true
+	}
+	return c.Conn.Write(b)
+}
+
+// traceAddr is the remote address of a traceConn carrying the timings of
+// one exchange, it ends up in the response via fasthttp.Response.RemoteAddr.
+type traceAddr struct {
+	net.Addr
+	dns, connect, tlsHandshake time.Duration
+	reused                     bool
+	start, firstByte, lastByte time.Time
+}
+
+func (a *traceAddr) fill(info *TraceInfo) {
+	info.DNSLookup = a.dns
+	info.Connect = a.connect
+	info.TLSHandshake = a.tlsHandshake
+	info.Reused = a.reused
+	if !a.firstByte.IsZero() {
+		info.TTFB = a.firstByte.Sub(a.start)
+		info.BodyRead = a.lastByte.Sub(a.firstByte)
+	}
+	if tcp, ok := a.Addr.(*net.TCPAddr); ok {
+		info.RemoteIP = tcp.IP.String()
+	} else if host, _, err := net.SplitHostPort(a.Addr.String()); err == nil {
+		info.RemoteIP = host
+	}
+}
@@ -0,0 +1,85 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTraceServers returns an http and an https server answering slowly
+// enough for the first byte to take measurable time.
+func newTraceServers(t *testing.T) map[string]*httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	servers := map[string]*httptest.Server{
+		"http":  httptest.NewServer(handler),
+		"https": httptest.NewTLSServer(handler),
+	}
+	for _, s := range servers {
+		t.Cleanup(s.Close)
+	}
+	return servers
+}
+
+// checkTraces checks the traces of two requests sent over one connection
+func checkTraces(t *testing.T, scheme string, traces []TraceInfo) {
+	t.Helper()
+	if len(traces) != 2 {
+		t.Fatalf("%s: got %d traces, want 2", scheme, len(traces))
+	}
+	first, second := traces[0], traces[1]
+	if first.Reused || !second.Reused {
+		t.Errorf("%s: got Reused %v then %v", scheme, first.Reused, second.Reused)
+	}
+	for i, info := range traces {
+		if info.TTFB <= 0 || info.RemoteIP != "127.0.0.1" {
+			t.Errorf("%s: trace %d got TTFB %v from %q", scheme, i, info.TTFB, info.RemoteIP)
+		}
+	}
+	if first.Connect <= 0 {
+		t.Errorf("%s: got Connect %v", scheme, first.Connect)
+	}
+	if (first.TLSHandshake > 0) != (scheme == "https") || second.TLSHandshake != 0 {
+		t.Errorf("%s: got TLSHandshake %v then %v", scheme, first.TLSHandshake, second.TLSHandshake)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	for scheme, s := range newTraceServers(t) {
+		var traces []TraceInfo
+		for i := 0; i < 2; i++ {
+			req, resp := AcquireRequestResponse()
+			req.Trace = &traces
+			// through localhost, which is resolved by the traced dialer
+			if err := req.Get(strings.Replace(s.URL, "127.0.0.1", "localhost", 1)).Do(resp); err != nil {
+				t.Fatalf("%s: %v", scheme, err)
+			}
+			ReleaseRequest(req)
+			ReleaseResponse(resp)
+		}
+		checkTraces(t, scheme, traces)
+	}
+}
+
+func TestTraceDialTriesEveryAddress(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+
+	// nothing listens on the first address
+	resolved.Store("multi.test", resolvedHost{ips: []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")}, at: time.Now()})
+	defer resolved.Delete("multi.test")
+	conn, err := traceDialer(nil, false, time.Second)(net.JoinHostPort("multi.test", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if c := conn.(*traceConn); c.dns != 0 || c.RemoteAddr().String() != s.Listener.Addr().String() {
+		t.Errorf("got DNS lookup %v, connected to %s", c.dns, c.RemoteAddr())
+	}
+}